@@ -1,8 +1,10 @@
 package tormenta
 
 import (
+	"context"
 	"time"
 
+	"github.com/dgraph-io/badger"
 	"github.com/jpincas/gouuidv6"
 )
 
@@ -19,10 +21,25 @@ func (db DB) Get(entity Record, ids ...gouuidv6.UUID) (bool, error) {
 func (db DB) GetWithContext(entity Record, ctx map[string]interface{}, ids ...gouuidv6.UUID) (bool, error) {
 	t := time.Now()
 
-	txn := db.KV.NewTransaction(false)
-	defer txn.Discard()
+	// The ID is set up front, so that middleware knows which entity is being retrieved
+	if len(ids) > 0 {
+		entity.SetID(ids[0])
+	}
+
+	op := Operation{
+		Type:    GetOperation,
+		KeyRoot: KeyRootString(entity),
+		Entity:  entity,
+		Ctx:     ctx,
+	}
 
-	ok, err := db.get(txn, entity, ctx, ids...)
+	var ok bool
+	err := db.run(op, func(Operation) error {
+		return db.view(func(txn *badger.Txn) (err error) {
+			ok, err = db.getLive(txn, entity, ctx)
+			return
+		})
+	})
 
 	if db.Options.DebugMode {
 		var n int
@@ -35,17 +52,53 @@ func (db DB) GetWithContext(entity Record, ctx map[string]interface{}, ids ...go
 	return ok, err
 }
 
+// GetCtx is like Get, but returns ctx.Err() without retrieving anything
+// if the context has already been cancelled or its deadline has passed
+func (db DB) GetCtx(ctx context.Context, entity Record, ids ...gouuidv6.UUID) (bool, error) {
+	if err := ctxErr(ctx); err != nil {
+		return false, err
+	}
+
+	return db.Get(entity, ids...)
+}
+
 func (db DB) GetIDs(target interface{}, ids ...gouuidv6.UUID) (int, error) {
 	return db.GetIDsWithContext(target, noCTX, ids...)
 }
 
 func (db DB) GetIDsWithContext(target interface{}, ctx map[string]interface{}, ids ...gouuidv6.UUID) (int, error) {
+	return db.getIDs(context.Background(), target, ctx, ids...)
+}
+
+// GetIDsCtx is like GetIDs, but stops fetching records and returns ctx.Err()
+// if the context is cancelled or its deadline passes
+func (db DB) GetIDsCtx(ctx context.Context, target interface{}, ids ...gouuidv6.UUID) (int, error) {
+	return db.getIDs(ctx, target, noCTX, ids...)
+}
+
+func (db DB) getIDs(goCtx context.Context, target interface{}, ctx map[string]interface{}, ids ...gouuidv6.UUID) (int, error) {
 	t := time.Now()
 
-	txn := db.KV.NewTransaction(false)
-	defer txn.Discard()
+	op := Operation{
+		Type:    GetOperation,
+		KeyRoot: string(KeyRoot(target)),
+		Target:  target,
+		Ctx:     ctx,
+		Context: goCtx,
+	}
+
+	var n int
+	err := db.run(op, func(Operation) error {
+		return db.view(func(txn *badger.Txn) (err error) {
+			liveIDs, err := excludeSoftDeleted(txn, KeyRoot(target), ids)
+			if err != nil {
+				return err
+			}
 
-	n, err := db.getIDsWithContext(txn, target, ctx, ids...)
+			n, err = db.getIDsWithContext(goCtx, txn, target, ctx, liveIDs...)
+			return
+		})
+	})
 
 	if db.Options.DebugMode {
 		debugLogGet(target, t, n, err, ids...)
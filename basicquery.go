@@ -1,6 +1,7 @@
 package tormenta
 
 import (
+	"context"
 	"time"
 
 	"github.com/dgraph-io/badger"
@@ -29,6 +30,12 @@ type basicQuery struct {
 
 	// Is already prepared?
 	prepared bool
+
+	// Cancellation context
+	goCtx context.Context
+
+	// Treatment of soft deleted records
+	deleted deletedMode
 }
 
 func (b *basicQuery) prepare() {
@@ -110,25 +117,40 @@ func (b basicQuery) isLimitMet(noIDsSoFar int) bool {
 	return b.limit > 0 && noIDsSoFar >= b.limit
 }
 
-func (b *basicQuery) queryIDs(txn *badger.Txn) (ids idList) {
+func (b *basicQuery) queryIDs(txn *badger.Txn) (ids idList, err error) {
 	if !b.prepared {
 		b.prepare()
 	}
 
 	b.reset()
 
+	deleted := newDeletedFilter(txn, b.keyRoot, b.deleted)
+
 	it := txn.NewIterator(b.getIteratorOptions())
 	defer it.Close()
 
 	for it.Seek(b.seekFrom); b.endIteration(it, len(ids)); it.Next() {
+		if err = ctxErr(b.goCtx); err != nil {
+			return nil, err
+		}
+
+		id := extractID(it.Item().Key())
+
+		// Soft deleted records are skipped before the offset is applied,
+		// so that they don't count towards it
+		if skip, err := deleted.skip(id); err != nil {
+			return nil, err
+		} else if skip {
+			continue
+		}
+
 		// Skip the first N entities according to the specified offset
 		if b.offsetCounter > 0 {
 			b.offsetCounter--
 			continue
 		}
 
-		item := it.Item()
-		ids = append(ids, extractID(item.Key()))
+		ids = append(ids, id)
 	}
 
 	return
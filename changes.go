@@ -0,0 +1,250 @@
+package tormenta
+
+import (
+	"context"
+	"sync"
+
+	"github.com/dgraph-io/badger"
+	"github.com/jpincas/gouuidv6"
+)
+
+// ChangeType says what happened to an entity
+type ChangeType int
+
+const (
+	Created ChangeType = iota + 1
+	Updated
+	Deleted
+)
+
+func (c ChangeType) String() string {
+	switch c {
+	case Created:
+		return "created"
+	case Updated:
+		return "updated"
+	case Deleted:
+		return "deleted"
+	}
+
+	return ""
+}
+
+// Buffer size of the channels returned by Watch
+const watchBufferSize = 100
+
+// Change describes a write to a single entity.
+// Before is the entity as it was before the write (nil when created),
+// and After is the entity as it was written (nil when deleted).
+// They are shallow copies, so treat them as read only
+type Change struct {
+	Type       ChangeType
+	EntityType string
+	ID         gouuidv6.UUID
+	Before     Record
+	After      Record
+}
+
+// changeFeed keeps track of subscribers, and of the changes made by
+// each transaction in progress, so that they can be sent out once it commits
+type changeFeed struct {
+	mu          sync.RWMutex
+	subscribers map[int]subscriber
+	nextID      int
+
+	pendingMu sync.Mutex
+	pending   map[*badger.Txn][]Change
+}
+
+type subscriber struct {
+	// The entity types to receive changes for - nil for all of them
+	entityTypes map[string]bool
+	fn          func(Change)
+}
+
+func newChangeFeed() *changeFeed {
+	return &changeFeed{
+		subscribers: map[int]subscriber{},
+		pending:     map[*badger.Txn][]Change{},
+	}
+}
+
+func (f *changeFeed) active() bool {
+	if f == nil {
+		return false
+	}
+
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return len(f.subscribers) > 0
+}
+
+func (f *changeFeed) subscribe(entityTypes []string, fn func(Change)) (unsubscribe func()) {
+	s := subscriber{fn: fn}
+	if len(entityTypes) > 0 {
+		s.entityTypes = map[string]bool{}
+		for _, entityType := range entityTypes {
+			s.entityTypes[entityType] = true
+		}
+	}
+
+	f.mu.Lock()
+	id := f.nextID
+	f.nextID++
+	f.subscribers[id] = s
+	f.mu.Unlock()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			f.mu.Lock()
+			delete(f.subscribers, id)
+			f.mu.Unlock()
+		})
+	}
+}
+
+// begin starts buffering the changes of a transaction
+func (f *changeFeed) begin(txn *badger.Txn) {
+	if f == nil {
+		return
+	}
+
+	f.pendingMu.Lock()
+	f.pending[txn] = nil
+	f.pendingMu.Unlock()
+}
+
+// end stops buffering the changes of a transaction and returns them
+func (f *changeFeed) end(txn *badger.Txn) []Change {
+	if f == nil {
+		return nil
+	}
+
+	f.pendingMu.Lock()
+	defer f.pendingMu.Unlock()
+
+	changes := f.pending[txn]
+	delete(f.pending, txn)
+	return changes
+}
+
+// record buffers a change against the transaction it was made in.
+// The change is only built if anyone is listening
+func (f *changeFeed) record(txn *badger.Txn, change func() Change) {
+	if !f.active() {
+		return
+	}
+
+	f.pendingMu.Lock()
+	defer f.pendingMu.Unlock()
+
+	if changes, ok := f.pending[txn]; ok {
+		f.pending[txn] = append(changes, change())
+	}
+}
+
+// publish sends committed changes to the subscribers.
+// Subscribers are called without holding any locks,
+// so they are free to use the DB themselves.
+// They are called on the goroutine that made the changes,
+// so until they return, that goroutine's write doesn't return either
+func (f *changeFeed) publish(changes []Change) {
+	if f == nil || len(changes) == 0 {
+		return
+	}
+
+	f.mu.RLock()
+	subscribers := make([]subscriber, 0, len(f.subscribers))
+	for _, s := range f.subscribers {
+		subscribers = append(subscribers, s)
+	}
+	f.mu.RUnlock()
+
+	for _, change := range changes {
+		for _, s := range subscribers {
+			if s.entityTypes == nil || s.entityTypes[change.EntityType] {
+				s.fn(change)
+			}
+		}
+	}
+}
+
+func newChange(changeType ChangeType, entity, before, after Record) Change {
+	return Change{
+		Type:       changeType,
+		EntityType: KeyRootString(entity),
+		ID:         entity.GetID(),
+		Before:     before,
+		After:      after,
+	}
+}
+
+// saveChange describes a save, given the previously stored version (nil if new)
+func saveChange(entity, previous Record) Change {
+	if previous == nil {
+		return newChange(Created, entity, nil, copyRecord(entity))
+	}
+
+	return newChange(Updated, entity, previous, copyRecord(entity))
+}
+
+func entityTypes(entities []interface{}) (types []string) {
+	for _, entity := range entities {
+		types = append(types, string(KeyRoot(entity)))
+	}
+
+	return
+}
+
+// Subscribe calls fn with every change made to entities of the given type
+// (e.g. &Order{}, or &[]Order{}) once the transaction making it has committed.
+// Changes made by Save, Delete, SoftDelete/Restore, Patch, query Update/Delete
+// and the bulk loader are all included; records disappearing through TTL expiry are not.
+// fn is called synchronously by whichever goroutine committed the change,
+// after the commit but before Save (or whatever made the change) returns,
+// so a slow fn slows down every write.  Hand anything slow off to another goroutine (or use Watch).
+// Call the returned function to unsubscribe.
+func (db DB) Subscribe(entity interface{}, fn func(Change)) (unsubscribe func()) {
+	return db.feed.subscribe(entityTypes([]interface{}{entity}), fn)
+}
+
+// Watch returns a channel which receives every change made to entities of the given types,
+// or of any type if none are given, once the transaction making it has committed.
+// See Subscribe for which changes are included.  The channel is closed when ctx is done.
+// The channel has a buffer of 100 changes.  Once it is full, writers wait for the reader to catch up
+// (or for ctx to be done) - changes are never dropped, but a stalled reader stalls every write,
+// so keep reading, and cancel ctx when you're done.
+func (db DB) Watch(ctx context.Context, entities ...interface{}) <-chan Change {
+	ch := make(chan Change, watchBufferSize)
+
+	// The lock makes sure we never close the channel while a change is being sent on it
+	var mu sync.Mutex
+	closed := false
+
+	unsubscribe := db.feed.subscribe(entityTypes(entities), func(c Change) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if closed {
+			return
+		}
+
+		select {
+		case ch <- c:
+		case <-ctx.Done():
+		}
+	})
+
+	go func() {
+		<-ctx.Done()
+		unsubscribe()
+
+		mu.Lock()
+		closed = true
+		close(ch)
+		mu.Unlock()
+	}()
+
+	return ch
+}
@@ -0,0 +1,134 @@
+package tormenta_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jpincas/tormenta"
+	"github.com/jpincas/tormenta/testtypes"
+)
+
+func Test_Subscribe(t *testing.T) {
+	db, _ := tormenta.OpenTestWithOptions("data/tests", testDBOptions)
+	defer db.Close()
+
+	var changes []tormenta.Change
+	unsubscribe := db.Subscribe(&testtypes.MiniStruct{}, func(c tormenta.Change) {
+		changes = append(changes, c)
+	})
+
+	miniStruct := testtypes.MiniStruct{IntField: 1}
+	db.Save(&miniStruct)
+
+	miniStruct.IntField = 2
+	db.Save(&miniStruct)
+
+	db.Delete(&testtypes.MiniStruct{}, miniStruct.ID)
+
+	// Other types should not be received
+	db.Save(&testtypes.FullStruct{})
+
+	// Nothing from a rolled back transaction
+	db.Update(func(tx *tormenta.Tx) error {
+		tx.Save(&testtypes.MiniStruct{})
+		return errors.New("rollback")
+	})
+
+	if len(changes) != 3 {
+		t.Fatalf("Testing subscribe. Expected 3 changes, got %v", len(changes))
+	}
+
+	expected := []tormenta.ChangeType{tormenta.Created, tormenta.Updated, tormenta.Deleted}
+	for i, change := range changes {
+		if change.Type != expected[i] {
+			t.Errorf("Testing subscribe. Expected change %v to be %s, got %s", i, expected[i], change.Type)
+		}
+
+		if change.ID != miniStruct.ID || change.EntityType != "ministruct" {
+			t.Errorf("Testing subscribe. Change %v has the wrong ID or type: %v / %s", i, change.ID, change.EntityType)
+		}
+	}
+
+	if changes[0].Before != nil || changes[0].After.(*testtypes.MiniStruct).IntField != 1 {
+		t.Error("Testing subscribe. Created change has the wrong before/after")
+	}
+
+	if changes[1].Before.(*testtypes.MiniStruct).IntField != 1 || changes[1].After.(*testtypes.MiniStruct).IntField != 2 {
+		t.Error("Testing subscribe. Updated change has the wrong before/after")
+	}
+
+	if changes[2].Before.(*testtypes.MiniStruct).IntField != 2 || changes[2].After != nil {
+		t.Error("Testing subscribe. Deleted change has the wrong before/after")
+	}
+
+	unsubscribe()
+	db.Save(&testtypes.MiniStruct{})
+	if len(changes) != 3 {
+		t.Errorf("Testing unsubscribe. Expected no more changes, got %v", len(changes))
+	}
+}
+
+func Test_Watch(t *testing.T) {
+	db, _ := tormenta.OpenTestWithOptions("data/tests", testDBOptions)
+	defer db.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	changes := db.Watch(ctx)
+
+	// Bulk operations
+	var toLoad []tormenta.Record
+	for i := 0; i < 10; i++ {
+		toLoad = append(toLoad, &testtypes.MiniStruct{IntField: i})
+	}
+	db.BulkLoad(toLoad...)
+	db.Find(&[]testtypes.MiniStruct{}).Range("IntField", 0, 4).Update(map[string]interface{}{"IntField": 100})
+	db.Find(&[]testtypes.MiniStruct{}).Match("IntField", 100).Delete()
+
+	counts := map[tormenta.ChangeType]int{}
+	timeout := time.After(5 * time.Second)
+
+	for counts[tormenta.Deleted] < 5 {
+		select {
+		case change := <-changes:
+			counts[change.Type]++
+		case <-timeout:
+			t.Fatalf("Testing watch. Timed out waiting for changes, got %v", counts)
+		}
+	}
+
+	if counts[tormenta.Created] != 10 || counts[tormenta.Updated] != 5 {
+		t.Errorf("Testing watch. Expected 10 created and 5 updated, got %v", counts)
+	}
+
+	// The channel is closed once the context is done
+	cancel()
+	for range changes {
+	}
+}
+
+func Test_Subscribe_Loader(t *testing.T) {
+	db, _ := tormenta.OpenTestWithOptions("data/tests", testDBOptions)
+	defer db.Close()
+
+	var changes int
+	db.Subscribe(&testtypes.MiniStruct{}, func(tormenta.Change) {
+		changes++
+	})
+
+	// Changes are sent as each batch is written, not when the loader is closed
+	l := db.NewLoader()
+	for i := 0; i < 1500; i++ {
+		l.Add(&testtypes.MiniStruct{IntField: i})
+	}
+
+	if changes != 1000 {
+		t.Errorf("Testing subscribe with the loader. Expected the changes of the first batch, got %v", changes)
+	}
+
+	l.Cancel()
+	if changes != 1000 {
+		t.Errorf("Testing subscribe with a cancelled loader. Expected no more changes, got %v", changes)
+	}
+}
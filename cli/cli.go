@@ -0,0 +1,290 @@
+// Package cli is the tormenta command line tool for looking at and maintaining a data directory
+// without writing any Go code.  The tormenta command (cmd/tormenta) runs it as it is:
+//
+//	tormenta -dir mydatadirectory types
+//	tormenta -dir mydatadirectory indexes order
+//	tormenta -dir mydatadirectory get order 0b8dc3e4a1a2...
+//	tormenta -dir mydatadirectory find order "where=index:Customer,match:jon&limit=10"
+//	tormenta -dir mydatadirectory dump > backup.json
+//	tormenta -dir mydatadirectory restore backup.json
+//	tormenta -dir mydatadirectory reindex
+//
+// The data directory is opened read-only, except for restore and reindex.
+// The tool doesn't know your Go types, so reindex isn't available (it exits with a usage error),
+// restore doesn't rebuild indexes (and warns about it), and find goes by the values given
+// rather than the types of the fields (see DB.FindRaw).
+// Records that aren't JSON (e.g. stored with the gob or msgpack codecs) are shown as quoted strings.
+// To get all of that, build your own copy of the command with your types:
+//
+//	func main() {
+//		os.Exit(cli.Run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr, &Order{}, &Customer{}))
+//	}
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/jpincas/gouuidv6"
+	"github.com/jpincas/tormenta"
+)
+
+const (
+	ErrNoDataDirectory   = "Data directory %s does not exist"
+	ErrUnknownCommand    = "Unknown command %s"
+	ErrWrongArguments    = "Wrong arguments for %s - usage: %s"
+	ErrRecordNotFound    = "No %s record with ID %s"
+	ErrNoRegisteredTypes = "reindex needs the Go types of the records, which this build of the tool doesn't have - " +
+		"build your own copy with your types to use it (see the cli package docs)"
+	ErrTypeNotRegistered = "%s is not one of the registered types"
+
+	warnNoIndexes = "Warning: no types are registered, so no indexes were rebuilt and indexed queries won't find the restored records.\n" +
+		"Reindex them with a build of the tool that has your Go types - see the cli package docs"
+)
+
+type command struct {
+	usage      string
+	writes     bool
+	needsTypes bool
+	minArgs    int
+	maxArgs    int
+	function   func(env, []string) error
+
+	// withoutTypes is added to the usage when no types are registered
+	withoutTypes string
+}
+
+// env is what a command has to work with
+type env struct {
+	db       *tormenta.DB
+	stdin    io.Reader
+	stdout   io.Writer
+	stderr   io.Writer
+	entities []tormenta.Record
+}
+
+var commands = map[string]command{
+	"types":   {usage: "types", maxArgs: 0, function: types},
+	"indexes": {usage: "indexes <type>", minArgs: 1, maxArgs: 1, function: indexes},
+	"get":     {usage: "get <type> <id>", minArgs: 2, maxArgs: 2, function: get},
+	"find":    {usage: `find <type> "<query string>"`, minArgs: 1, maxArgs: 2, function: find},
+	"dump":    {usage: "dump", maxArgs: 0, function: dump},
+	"restore": {usage: "restore [file]", writes: true, maxArgs: 1, function: restore,
+		withoutTypes: "doesn't rebuild indexes - run reindex from a build with your types afterwards"},
+	"reindex": {usage: "reindex [type]", writes: true, needsTypes: true, maxArgs: 1, function: reindex,
+		withoutTypes: "not available - needs a build with your types"},
+}
+
+// commandOrder is the order commands are listed in the usage message
+var commandOrder = []string{"types", "indexes", "get", "find", "dump", "restore", "reindex"}
+
+// Run runs the command line tool with the given arguments (without the program name)
+// and returns the exit code.  The entity types given are registered with the DB,
+// which lets reindex and restore rebuild their indexes, and find use their field types
+func Run(args []string, stdin io.Reader, stdout, stderr io.Writer, entities ...tormenta.Record) int {
+	flags := flag.NewFlagSet("tormenta", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	dir := flags.String("dir", "data", "the data directory")
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: tormenta [-dir directory] <command> [arguments]")
+		fmt.Fprintln(stderr, "Commands:")
+		for _, name := range commandOrder {
+			if cmd := commands[name]; len(entities) == 0 && cmd.withoutTypes != "" {
+				fmt.Fprintf(stderr, "  %s (%s)\n", cmd.usage, cmd.withoutTypes)
+			} else {
+				fmt.Fprintln(stderr, "  "+cmd.usage)
+			}
+		}
+	}
+
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return 2
+	}
+
+	name, commandArgs := flags.Arg(0), flags.Args()[1:]
+	cmd, ok := commands[name]
+	if !ok {
+		fmt.Fprintf(stderr, ErrUnknownCommand+"\n", name)
+		flags.Usage()
+		return 2
+	}
+
+	if len(commandArgs) < cmd.minArgs || len(commandArgs) > cmd.maxArgs {
+		fmt.Fprintf(stderr, ErrWrongArguments+"\n", name, cmd.usage)
+		return 2
+	}
+
+	if cmd.needsTypes && len(entities) == 0 {
+		fmt.Fprintln(stderr, ErrNoRegisteredTypes)
+		return 2
+	}
+
+	db, err := open(*dir, !cmd.writes)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	defer db.Close()
+
+	db.Register(entities...)
+
+	if err := cmd.function(env{db: db, stdin: stdin, stdout: stdout, stderr: stderr, entities: entities}, commandArgs); err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+
+	return 0
+}
+
+// open opens an existing data directory, read-only if required
+func open(dir string, readOnly bool) (*tormenta.DB, error) {
+	if _, err := os.Stat(dir); err != nil {
+		return nil, fmt.Errorf(ErrNoDataDirectory, dir)
+	}
+
+	options := tormenta.DefaultOptions
+	options.BadgerOptions.ReadOnly = readOnly
+	return tormenta.OpenWithOptions(dir, options)
+}
+
+func types(e env, args []string) error {
+	counts, err := e.db.StoredTypes()
+	if err != nil {
+		return err
+	}
+
+	return printCounts(e.stdout, "TYPE", "RECORDS", counts)
+}
+
+func indexes(e env, args []string) error {
+	counts, err := e.db.StoredIndexes(args[0])
+	if err != nil {
+		return err
+	}
+
+	return printCounts(e.stdout, "INDEX", "KEYS", counts)
+}
+
+func printCounts(w io.Writer, nameHeading, countHeading string, counts []tormenta.KeyCount) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(tw, "%s\t%s\n", nameHeading, countHeading)
+	for _, count := range counts {
+		fmt.Fprintf(tw, "%s\t%v\n", count.Name, count.Keys)
+	}
+
+	return tw.Flush()
+}
+
+func get(e env, args []string) error {
+	var id gouuidv6.UUID
+	if err := json.Unmarshal([]byte(`"`+args[1]+`"`), &id); err != nil {
+		return fmt.Errorf(tormenta.ErrBadIDFormat, args[1])
+	}
+
+	value, found, err := e.db.GetRaw(args[0], id)
+	if err != nil {
+		return err
+	} else if !found {
+		return fmt.Errorf(ErrRecordNotFound, args[0], args[1])
+	}
+
+	// Pretty print JSON, otherwise show the value escaped
+	pretty := bytes.Buffer{}
+	if err := json.Indent(&pretty, value, "", "  "); err != nil {
+		_, err = fmt.Fprintln(e.stdout, printable(value))
+		return err
+	}
+
+	_, err = fmt.Fprintln(e.stdout, pretty.String())
+	return err
+}
+
+// printable gives a value as it is if it is JSON, otherwise as a quoted string,
+// so that binary values don't mess up the terminal
+func printable(value []byte) string {
+	if json.Valid(value) {
+		return string(value)
+	}
+
+	return strconv.Quote(string(value))
+}
+
+// find writes the matching records, one per line
+func find(e env, args []string) error {
+	q := e.db.FindRaw(args[0])
+	if len(args) > 1 {
+		if err := q.Parse(false, args[1]); err != nil {
+			return err
+		}
+	}
+
+	records, err := q.RunRaw()
+	if err != nil {
+		return err
+	}
+
+	for _, record := range records {
+		if _, err := fmt.Fprintln(e.stdout, printable(record.Value)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func dump(e env, args []string) error {
+	return e.db.Dump(e.stdout)
+}
+
+func restore(e env, args []string) error {
+	r := e.stdin
+	if len(args) > 0 {
+		f, err := os.Open(args[0])
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		r = f
+	}
+
+	n, err := e.db.RestoreDump(r)
+	fmt.Fprintf(e.stdout, "%v records restored\n", n)
+
+	if len(e.entities) == 0 && n > 0 {
+		fmt.Fprintln(e.stderr, warnNoIndexes)
+	}
+
+	return err
+}
+
+func reindex(e env, args []string) error {
+	progress := func(p tormenta.Progress) {
+		fmt.Fprintf(e.stdout, "%s: %v/%v\n", p.KeyRoot, p.Done, p.Total)
+	}
+
+	if len(args) == 0 {
+		_, err := e.db.ReindexAll(progress)
+		return err
+	}
+
+	for _, entity := range e.entities {
+		if tormenta.KeyRootString(entity) == args[0] {
+			_, err := e.db.RebuildIndexes(entity, progress)
+			return err
+		}
+	}
+
+	return fmt.Errorf(ErrTypeNotRegistered, args[0])
+}
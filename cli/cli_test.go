@@ -0,0 +1,188 @@
+package cli_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/jpincas/gouuidv6"
+	"github.com/jpincas/tormenta"
+	"github.com/jpincas/tormenta/cli"
+)
+
+type Order struct {
+	tormenta.Model
+
+	Customer string
+	Amount   int
+}
+
+const testDir = "data/tests-test"
+
+// setup saves some orders in a fresh test DB, then closes it so the tool can open it
+func setup(t *testing.T) []Order {
+	db, err := tormenta.OpenTest("data/tests")
+	if err != nil {
+		t.Fatalf("Couldn't open test DB: %v", err)
+	}
+	defer db.Close()
+
+	orders := []Order{{Customer: "jon", Amount: 10}, {Customer: "jon", Amount: 20}, {Customer: "ann", Amount: 30}}
+	for i := range orders {
+		db.Save(&orders[i])
+	}
+
+	return orders
+}
+
+// idString gives an ID the way it appears in stored records
+func idString(id gouuidv6.UUID) string {
+	b, _ := json.Marshal(id)
+	return strings.Trim(string(b), `"`)
+}
+
+func run(stdin string, args ...string) (code int, stdout, stderr string) {
+	out, errOut := bytes.Buffer{}, bytes.Buffer{}
+	code = cli.Run(append([]string{"-dir", testDir}, args...), strings.NewReader(stdin), &out, &errOut, &Order{})
+	return code, out.String(), errOut.String()
+}
+
+func Test_Commands(t *testing.T) {
+	orders := setup(t)
+
+	testCases := []struct {
+		testName string
+		args     []string
+		expected []string
+	}{
+		{"types", []string{"types"}, []string{"order", "3"}},
+		{"indexes", []string{"indexes", "order"}, []string{"Customer", "Amount"}},
+		{"get", []string{"get", "order", idString(orders[2].ID)}, []string{`"Customer": "ann"`}},
+		{"find", []string{"find", "order", "where=index:Customer,match:jon&limit=1"}, []string{`"Customer":"jon"`}},
+		{"dump", []string{"dump"}, []string{`"type":"order"`}},
+		{"reindex", []string{"reindex", "order"}, []string{"order: 3/3"}},
+	}
+
+	for _, testCase := range testCases {
+		code, stdout, stderr := run("", testCase.args...)
+		if code != 0 {
+			t.Errorf("Testing %s. Expected exit code 0, got %v (%s)", testCase.testName, code, stderr)
+		}
+
+		for _, expected := range testCase.expected {
+			if !strings.Contains(stdout, expected) {
+				t.Errorf("Testing %s. Expected the output to contain %s, got %s", testCase.testName, expected, stdout)
+			}
+		}
+	}
+
+	// find respects the limit
+	if _, stdout, _ := run("", "find", "order", "where=index:Customer,match:jon&limit=1"); strings.Count(stdout, "\n") != 1 {
+		t.Errorf("Testing find. Expected 1 record, got %s", stdout)
+	}
+}
+
+func Test_DumpRestore(t *testing.T) {
+	orders := setup(t)
+
+	_, dump, _ := run("", "dump")
+
+	// Restore into an empty DB
+	db, _ := tormenta.OpenTest("data/tests")
+	db.Close()
+
+	if code, stdout, stderr := run(dump, "restore"); code != 0 || !strings.Contains(stdout, "3 records restored") {
+		t.Fatalf("Testing restore. Got exit code %v, output %s %s", code, stdout, stderr)
+	}
+
+	// Registered types are reindexed
+	if _, stdout, _ := run("", "find", "order", "where=index:Amount,start:15"); strings.Count(stdout, "\n") != 2 {
+		t.Errorf("Testing restore. Expected 2 restored records found by index, got %s", stdout)
+	}
+
+	if _, stdout, _ := run("", "get", "order", idString(orders[0].ID)); !strings.Contains(stdout, `"Amount": 10`) {
+		t.Errorf("Testing restore. Expected to get the record by its original ID, got %s", stdout)
+	}
+}
+
+func Test_Restore_NoTypes(t *testing.T) {
+	setup(t)
+
+	_, dump, _ := run("", "dump")
+
+	db, _ := tormenta.OpenTest("data/tests")
+	db.Close()
+
+	// Without the types, nothing can be reindexed, which should be pointed out
+	out, errOut := bytes.Buffer{}, bytes.Buffer{}
+	if code := cli.Run([]string{"-dir", testDir, "restore"}, strings.NewReader(dump), &out, &errOut); code != 0 {
+		t.Fatalf("Testing restore without types. Got exit code %v (%s)", code, errOut.String())
+	}
+
+	if !strings.Contains(out.String(), "3 records restored") || !strings.Contains(errOut.String(), "no indexes were rebuilt") {
+		t.Errorf("Testing restore without types. Expected a warning about indexes, got %s %s", out.String(), errOut.String())
+	}
+}
+
+func Test_Binary(t *testing.T) {
+	options := tormenta.DefaultOptions
+	options.Codec = tormenta.GobCodec
+	db, _ := tormenta.OpenTestWithOptions("data/tests", options)
+	order := Order{Customer: "jon", Amount: 10}
+	db.Save(&order)
+	db.Close()
+
+	// Gob isn't JSON, so is shown escaped
+	for _, args := range [][]string{{"get", "order", idString(order.ID)}, {"find", "order"}} {
+		if _, stdout, _ := run("", args...); !strings.HasPrefix(stdout, `"`) || !strings.Contains(stdout, `\x`) {
+			t.Errorf("Testing %s of a binary record. Expected an escaped value, got %s", args[0], stdout)
+		}
+	}
+}
+
+func Test_Errors(t *testing.T) {
+	setup(t)
+
+	testCases := []struct {
+		testName string
+		args     []string
+		code     int
+	}{
+		{"no command", []string{}, 2},
+		{"unknown command", []string{"frobnicate"}, 2},
+		{"missing arguments", []string{"get", "order"}, 2},
+		{"bad id", []string{"get", "order", "nonsense"}, 1},
+		{"bad query", []string{"find", "order", "limit=ten"}, 1},
+		{"unregistered type", []string{"reindex", "customer"}, 1},
+	}
+
+	for _, testCase := range testCases {
+		if code, _, _ := run("", testCase.args...); code != testCase.code {
+			t.Errorf("Testing %s. Expected exit code %v, got %v", testCase.testName, testCase.code, code)
+		}
+	}
+
+	// Reindexing is impossible without the Go types, which is a usage error
+	out := bytes.Buffer{}
+	if code := cli.Run([]string{"-dir", testDir, "reindex"}, nil, &out, &out); code != 2 || !strings.Contains(out.String(), "Go types") {
+		t.Errorf("Testing reindex without types. Expected exit code 2 and an explanation, got %v (%s)", code, out.String())
+	}
+
+	// ...and the usage says so, as well as that restore won't rebuild indexes
+	out.Reset()
+	cli.Run(nil, nil, &out, &out)
+	if !strings.Contains(out.String(), "reindex [type] (not available") || !strings.Contains(out.String(), "restore [file] (doesn't rebuild indexes") {
+		t.Errorf("Testing usage without types. Expected notes on reindex and restore, got %s", out.String())
+	}
+
+	out.Reset()
+	cli.Run(nil, nil, &out, &out, &Order{})
+	if strings.Contains(out.String(), "not available") {
+		t.Errorf("Testing usage with types. Expected no notes, got %s", out.String())
+	}
+
+	if code := cli.Run([]string{"-dir", "data/doesnotexist", "types"}, nil, &out, &out); code != 1 {
+		t.Errorf("Testing a missing data directory. Expected exit code 1, got %v", code)
+	}
+}
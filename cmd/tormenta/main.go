@@ -0,0 +1,13 @@
+// Command tormenta looks at and maintains a tormenta data directory - see the cli package.
+// It doesn't know your Go types, so it can't rebuild indexes - build your own copy with them for that
+package main
+
+import (
+	"os"
+
+	"github.com/jpincas/tormenta/cli"
+)
+
+func main() {
+	os.Exit(cli.Run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
@@ -0,0 +1,256 @@
+package tormenta
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+
+	"github.com/vmihailenco/msgpack"
+)
+
+// Values encoded with a codec are stored as a zero byte, then the codec identifier, then the data.
+// Values written with SerialiseFunc are stored as they are, so the zero byte is what tells them apart:
+// it can't start JSON, and doesn't start protobuf, gob or msgpack encoded structs either.
+// To keep it that way, values from SerialiseFunc that start with a zero byte are refused
+const (
+	codecMarker byte = 0
+
+	codecIDJSON    byte = 1
+	codecIDGob     byte = 2
+	codecIDMsgpack byte = 3
+
+	minCustomCodecID byte = 16
+
+	ErrCodecID            = "Codec ID %v is not available - custom codecs must use IDs from 16 to 255"
+	ErrCodecRegistered    = "Codec %s, or codec ID %v, has already been registered"
+	ErrCodecNotRegistered = "Codec %s has not been registered - see RegisterCodec"
+	ErrCodecUnknownID     = "Value was stored with codec ID %v, which has not been registered - see RegisterCodec"
+	ErrSerialisedValue    = "Values from SerialiseFunc can't start with a zero byte, as it marks values stored with a codec"
+)
+
+// Codec encodes and decodes records for storage.
+// Set the codec for the whole DB with Options.Codec, or for a single entity type with a Codec method (see CodecSelector)
+type Codec interface {
+	// Name identifies the codec, and must be unique
+	Name() string
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+// CodecSelector is implemented by entities which should be stored with a different codec
+// from the rest of the DB
+type CodecSelector interface {
+	Codec() Codec
+}
+
+// The built in codecs
+var (
+	JSONCodec    Codec = jsonCodec{}
+	GobCodec     Codec = gobCodec{}
+	MsgpackCodec Codec = msgpackCodec{}
+)
+
+type jsonCodec struct{}
+
+func (jsonCodec) Name() string                               { return "json" }
+func (jsonCodec) Marshal(v interface{}) ([]byte, error)      { return json.Marshal(v) }
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error { return json.Unmarshal(data, v) }
+
+// gobCodec records can't be migrated with Migration.Map,
+// as gob can't decode a struct into a map
+type gobCodec struct{}
+
+func (gobCodec) Name() string { return "gob" }
+
+func (gobCodec) Marshal(v interface{}) ([]byte, error) {
+	buf := bytes.Buffer{}
+	err := gob.NewEncoder(&buf).Encode(v)
+	return buf.Bytes(), err
+}
+
+func (gobCodec) Unmarshal(data []byte, v interface{}) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+// msgpackCodec uses JSON field names, so that records look the same
+// to migrations whichever of the two they are stored with
+type msgpackCodec struct{}
+
+func (msgpackCodec) Name() string { return "msgpack" }
+
+func (msgpackCodec) Marshal(v interface{}) ([]byte, error) {
+	buf := bytes.Buffer{}
+	err := msgpack.NewEncoder(&buf).UseJSONTag(true).Encode(v)
+	return buf.Bytes(), err
+}
+
+func (msgpackCodec) Unmarshal(data []byte, v interface{}) error {
+	return msgpack.NewDecoder(bytes.NewReader(data)).UseJSONTag(true).Decode(v)
+}
+
+// codecTable holds the codecs that can be used, by identifier and by name
+type codecTable struct {
+	mu     sync.RWMutex
+	byID   map[byte]Codec
+	byName map[string]byte
+}
+
+var codecs = codecTable{
+	byID: map[byte]Codec{
+		codecIDJSON:    JSONCodec,
+		codecIDGob:     GobCodec,
+		codecIDMsgpack: MsgpackCodec,
+	},
+	byName: map[string]byte{
+		JSONCodec.Name():    codecIDJSON,
+		GobCodec.Name():     codecIDGob,
+		MsgpackCodec.Name(): codecIDMsgpack,
+	},
+}
+
+// RegisterCodec makes a custom codec available under an identifier, which is stored with each value it encodes,
+// so that values can be decoded whatever codec is currently set.  Identifiers from 16 to 255 are available.
+// The identifier of a codec must not change once anything has been stored with it
+func RegisterCodec(id byte, codec Codec) error {
+	if id < minCustomCodecID {
+		return fmt.Errorf(ErrCodecID, id)
+	}
+
+	codecs.mu.Lock()
+	defer codecs.mu.Unlock()
+
+	if _, ok := codecs.byID[id]; ok {
+		return fmt.Errorf(ErrCodecRegistered, codec.Name(), id)
+	}
+
+	if _, ok := codecs.byName[codec.Name()]; ok {
+		return fmt.Errorf(ErrCodecRegistered, codec.Name(), id)
+	}
+
+	codecs.byID[id] = codec
+	codecs.byName[codec.Name()] = id
+	return nil
+}
+
+func codecByName(name string) (Codec, bool) {
+	codecs.mu.RLock()
+	defer codecs.mu.RUnlock()
+
+	id, ok := codecs.byName[name]
+	return codecs.byID[id], ok
+}
+
+// splitValue splits a stored value into the codec it was encoded with,
+// which is nil for values written with SerialiseFunc, and the encoded data
+func splitValue(value []byte) (Codec, []byte, error) {
+	if len(value) == 0 || value[0] != codecMarker {
+		return nil, value, nil
+	}
+
+	if len(value) < 2 {
+		return nil, nil, fmt.Errorf(ErrCodecUnknownID, "(missing)")
+	}
+
+	codecs.mu.RLock()
+	defer codecs.mu.RUnlock()
+
+	codec, ok := codecs.byID[value[1]]
+	if !ok {
+		return nil, nil, fmt.Errorf(ErrCodecUnknownID, value[1])
+	}
+
+	return codec, value[2:], nil
+}
+
+// joinValue puts the marker and the identifier of a codec in front of the data it has encoded.
+// Data from SerialiseFunc (a nil codec) is left as it is, as long as it can't be mistaken for it
+func joinValue(codec Codec, data []byte) ([]byte, error) {
+	if codec == nil {
+		if len(data) > 0 && data[0] == codecMarker {
+			return nil, errors.New(ErrSerialisedValue)
+		}
+
+		return data, nil
+	}
+
+	codecs.mu.RLock()
+	id, ok := codecs.byName[codec.Name()]
+	codecs.mu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf(ErrCodecNotRegistered, codec.Name())
+	}
+
+	return append([]byte{codecMarker, id}, data...), nil
+}
+
+// codecFor works out the codec to store an entity with.
+// nil means SerialiseFunc
+func (db DB) codecFor(entity Record) Codec {
+	if selector, ok := entity.(CodecSelector); ok {
+		if codec := selector.Codec(); codec != nil {
+			return codec
+		}
+	}
+
+	return db.Options.Codec
+}
+
+// marshal encodes a value with a codec, or with SerialiseFunc if the codec is nil,
+// ready to be stored
+func (db DB) marshal(codec Codec, v interface{}) ([]byte, error) {
+	var data []byte
+	var err error
+	if codec == nil {
+		data, err = db.Options.SerialiseFunc(v)
+	} else {
+		data, err = codec.Marshal(v)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return joinValue(codec, data)
+}
+
+// unmarshal decodes data with a codec, or with UnserialiseFunc if the codec is nil
+func (db DB) unmarshal(codec Codec, data []byte, v interface{}) error {
+	if codec == nil {
+		return db.Options.UnserialiseFunc(data, v)
+	}
+
+	return codec.Unmarshal(data, v)
+}
+
+// withoutSkippedFields returns a copy of an entity with the fields that aren't to be saved
+// (including those of nested structs) set to their zero values.
+// SerialiseFunc gets a map without them instead (see structToMap), but codecs get the struct
+// itself, as not all of them can decode a map back into a struct
+func withoutSkippedFields(entityValue reflect.Value) interface{} {
+	c := reflect.New(entityValue.Type())
+	c.Elem().Set(entityValue)
+	zeroSkippedFields(c.Elem())
+	return c.Interface()
+}
+
+func zeroSkippedFields(v reflect.Value) {
+	for i := 0; i < v.NumField(); i++ {
+		fieldType := v.Type().Field(i)
+		field := v.Field(i)
+
+		if !field.CanSet() {
+			continue
+		}
+
+		if isTaggedWith(fieldType, tormentaTagNoSave) {
+			field.Set(reflect.Zero(fieldType.Type))
+		} else if fieldType.Type.Kind() == reflect.Struct {
+			zeroSkippedFields(field)
+		}
+	}
+}
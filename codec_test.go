@@ -0,0 +1,292 @@
+package tormenta_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/dgraph-io/badger"
+	"github.com/jpincas/gouuidv6"
+	"github.com/jpincas/tormenta"
+	"github.com/jpincas/tormenta/testtypes"
+)
+
+// storedValue reads the value stored for a record, straight from Badger
+func storedValue(db *tormenta.DB, root string, id gouuidv6.UUID) (value []byte) {
+	key := strings.Join([]string{"c", root, string(id.Bytes())}, "~±^")
+	db.KV.View(func(txn *badger.Txn) error {
+		item, err := txn.Get([]byte(key))
+		if err != nil {
+			return err
+		}
+
+		value, err = item.ValueCopy(nil)
+		return err
+	})
+
+	return
+}
+
+// storedWith reports whether a stored value is marked as stored with the codec with the given ID
+func storedWith(value []byte, id byte) bool {
+	return len(value) > 1 && value[0] == 0 && value[1] == id
+}
+
+func Test_Codecs(t *testing.T) {
+	testCases := []struct {
+		codec      tormenta.Codec
+		identifier byte
+	}{
+		{tormenta.JSONCodec, 1},
+		{tormenta.GobCodec, 2},
+		{tormenta.MsgpackCodec, 3},
+	}
+
+	for _, testCase := range testCases {
+		options := testDBOptions
+		options.Codec = testCase.codec
+		db, _ := tormenta.OpenTestWithOptions("data/tests", options)
+
+		entity := testtypes.CodecStruct{
+			Name:    "jon",
+			Amount:  9.99,
+			Tags:    []string{"a", "b"},
+			Address: testtypes.CodecAddress{City: "London", Skipped: "skipped"},
+			Skipped: "skipped",
+		}
+
+		if _, err := db.Save(&entity); err != nil {
+			t.Errorf("Testing %s codec. Got error saving: %v", testCase.codec.Name(), err)
+		}
+
+		if value := storedValue(db, "codecstruct", entity.ID); !storedWith(value, testCase.identifier) {
+			t.Errorf("Testing %s codec. Expected the value to start with the codec identifier %v, got %v", testCase.codec.Name(), testCase.identifier, value)
+		}
+
+		result := testtypes.CodecStruct{}
+		if found, err := db.Get(&result, entity.ID); !found || err != nil {
+			t.Errorf("Testing %s codec. Expected to get the record back, got error %v", testCase.codec.Name(), err)
+		}
+
+		if result.Name != "jon" || result.Amount != 9.99 || len(result.Tags) != 2 || result.Address.City != "London" || !result.LastUpdated.Equal(entity.LastUpdated) {
+			t.Errorf("Testing %s codec. Record didn't survive the round trip: %v", testCase.codec.Name(), result)
+		}
+
+		if result.Skipped != "" || result.Address.Skipped != "" {
+			t.Errorf("Testing %s codec. Expected fields tagged not to be saved to be left out", testCase.codec.Name())
+		}
+
+		if n, _ := db.Find(&[]testtypes.CodecStruct{}).Match("Name", "jon").Count(); n != 1 {
+			t.Errorf("Testing %s codec. Expected the record to be indexed, got %v results", testCase.codec.Name(), n)
+		}
+
+		db.Close()
+	}
+}
+
+func Test_Codec_Switch(t *testing.T) {
+	db, _ := tormenta.OpenTestWithOptions("data/tests", testDBOptions)
+	defer db.Close()
+
+	// Stored with SerialiseFunc, so plain JSON
+	entity := testtypes.CodecStruct{Name: "jon"}
+	db.Save(&entity)
+
+	if value := storedValue(db, "codecstruct", entity.ID); value[0] != '{' {
+		t.Errorf("Testing codec switch. Expected plain JSON without a codec, got %s", value)
+	}
+
+	// Old records can still be read after switching codec,
+	// and are stored with the new codec once saved again
+	db.Options.Codec = tormenta.GobCodec
+
+	result := testtypes.CodecStruct{}
+	if found, err := db.Get(&result, entity.ID); !found || err != nil || result.Name != "jon" {
+		t.Errorf("Testing codec switch. Expected to read the JSON record, got %v (error %v)", result, err)
+	}
+
+	result.Name = "jonathan"
+	db.Save(&result)
+
+	if value := storedValue(db, "codecstruct", entity.ID); !storedWith(value, 2) {
+		t.Errorf("Testing codec switch. Expected the record to have been stored with gob, got %v", value)
+	}
+
+	// Switching again, the gob record can still be read
+	db.Options.Codec = tormenta.MsgpackCodec
+	result = testtypes.CodecStruct{}
+	if db.Get(&result, entity.ID); result.Name != "jonathan" {
+		t.Errorf("Testing codec switch. Expected to read the gob record, got %v", result)
+	}
+}
+
+func Test_Codec_PerType(t *testing.T) {
+	db, _ := tormenta.OpenTestWithOptions("data/tests", testDBOptions)
+	defer db.Close()
+
+	entity := testtypes.MsgpackStruct{Name: "jon"}
+	db.Save(&entity)
+
+	if value := storedValue(db, "msgpackstruct", entity.ID); !storedWith(value, 3) {
+		t.Errorf("Testing per type codec. Expected the record to be stored with msgpack, got %v", value)
+	}
+
+	result := testtypes.MsgpackStruct{}
+	if db.Get(&result, entity.ID); result.Name != "jon" {
+		t.Errorf("Testing per type codec. Expected to get the record back, got %v", result)
+	}
+
+	// Migrations keep the codec
+	if err := db.Migrate(tormenta.Migration{
+		Entity:  &testtypes.MsgpackStruct{},
+		Version: 1,
+		Map: func(record map[string]interface{}) error {
+			record["Name"] = strings.ToUpper(record["Name"].(string))
+			return nil
+		},
+	}); err != nil {
+		t.Fatalf("Testing per type codec migration. Got error %v", err)
+	}
+
+	result = testtypes.MsgpackStruct{}
+	if db.Get(&result, entity.ID); result.Name != "JON" {
+		t.Errorf("Testing per type codec migration. Expected the record to be migrated, got %v", result)
+	}
+
+	if value := storedValue(db, "msgpackstruct", entity.ID); !storedWith(value, 3) {
+		t.Errorf("Testing per type codec migration. Expected the record to still be stored with msgpack, got %v", value)
+	}
+}
+
+// indentCodec is indented JSON, so that it can be told apart from the built in JSON codec
+type indentCodec struct{}
+
+func (indentCodec) Name() string { return "indent" }
+
+func (indentCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.MarshalIndent(v, "", "  ")
+}
+
+func (indentCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+// unregisteredCodec hasn't been registered, so can't be used
+type unregisteredCodec struct{ indentCodec }
+
+func (unregisteredCodec) Name() string { return "unregistered" }
+
+func Test_RegisterCodec(t *testing.T) {
+	if err := tormenta.RegisterCodec(1, indentCodec{}); err == nil {
+		t.Error("Testing codec registration. Expected an error using a reserved ID")
+	}
+
+	if err := tormenta.RegisterCodec(15, indentCodec{}); err == nil {
+		t.Error("Testing codec registration. Expected an error using an ID below the custom range")
+	}
+
+	if err := tormenta.RegisterCodec(100, indentCodec{}); err != nil {
+		t.Fatalf("Testing codec registration. Got error %v", err)
+	}
+
+	if err := tormenta.RegisterCodec(101, indentCodec{}); err == nil {
+		t.Error("Testing codec registration. Expected an error registering the same codec twice")
+	}
+
+	options := testDBOptions
+	options.Codec = indentCodec{}
+	db, _ := tormenta.OpenTestWithOptions("data/tests", options)
+	defer db.Close()
+
+	entity := testtypes.CodecStruct{Name: "jon"}
+	db.Save(&entity)
+
+	if value := storedValue(db, "codecstruct", entity.ID); !storedWith(value, 100) || !bytes.Contains(value, []byte("\n  ")) {
+		t.Errorf("Testing custom codec. Expected the record to be stored with it, got %s", value)
+	}
+
+	result := testtypes.CodecStruct{}
+	if db.Get(&result, entity.ID); result.Name != "jon" {
+		t.Errorf("Testing custom codec. Expected to get the record back, got %v", result)
+	}
+
+	db.Options.Codec = unregisteredCodec{}
+	if _, err := db.Save(&testtypes.CodecStruct{}); err == nil {
+		t.Error("Testing unregistered codec. Expected an error saving with it")
+	}
+}
+
+func Test_Codec_DumpRestore(t *testing.T) {
+	options := testDBOptions
+	options.Codec = tormenta.JSONCodec
+	db, _ := tormenta.OpenTestWithOptions("data/tests", options)
+	defer db.Close()
+
+	entity := testtypes.CodecStruct{Name: "jon"}
+	msgpackEntity := testtypes.MsgpackStruct{Name: "ann"}
+	db.Save(&entity, &msgpackEntity)
+
+	buf := bytes.Buffer{}
+	db.Dump(&buf)
+
+	// JSON is still readable in the dump
+	if !strings.Contains(buf.String(), `"codec":"json","data":{`) || !strings.Contains(buf.String(), `"codec":"msgpack","raw":`) {
+		t.Errorf("Testing dump with codecs. Expected codec names and readable JSON, got %s", buf.String())
+	}
+
+	restoreDB, _ := tormenta.OpenTestWithOptions("data/tests-restore", testDBOptions)
+	defer restoreDB.Close()
+
+	if _, err := restoreDB.RestoreDump(&buf); err != nil {
+		t.Fatalf("Testing restore with codecs. Got error %v", err)
+	}
+
+	result := testtypes.CodecStruct{}
+	msgpackResult := testtypes.MsgpackStruct{}
+	restoreDB.Get(&result, entity.ID)
+	restoreDB.Get(&msgpackResult, msgpackEntity.ID)
+
+	if result.Name != "jon" || msgpackResult.Name != "ann" {
+		t.Errorf("Testing restore with codecs. Expected both records back, got %v and %v", result, msgpackResult)
+	}
+
+	if value := storedValue(restoreDB, "codecstruct", entity.ID); !storedWith(value, 1) {
+		t.Errorf("Testing restore with codecs. Expected the record to keep its codec, got %v", value)
+	}
+}
+
+func Test_Codec_BinarySerialiseFunc(t *testing.T) {
+	options := testDBOptions
+
+	// A binary serialiser whose values start with the IDs of the built in codecs
+	options.SerialiseFunc = func(v interface{}) ([]byte, error) {
+		data, err := json.Marshal(v)
+		return append([]byte{1, 2, 3}, data...), err
+	}
+	options.UnserialiseFunc = func(data []byte, v interface{}) error {
+		return json.Unmarshal(data[3:], v)
+	}
+
+	db, _ := tormenta.OpenTestWithOptions("data/tests", options)
+	defer db.Close()
+
+	entity := testtypes.CodecStruct{Name: "jon"}
+	if _, err := db.Save(&entity); err != nil {
+		t.Fatalf("Testing binary serialiser. Got error saving: %v", err)
+	}
+
+	result := testtypes.CodecStruct{}
+	if found, err := db.Get(&result, entity.ID); !found || err != nil || result.Name != "jon" {
+		t.Errorf("Testing binary serialiser. Expected the record back, got %v (error %v)", result, err)
+	}
+
+	// Values starting with a zero byte would be taken for values stored with a codec
+	db.Options.SerialiseFunc = func(v interface{}) ([]byte, error) {
+		return []byte{0, 1}, nil
+	}
+
+	if _, err := db.Save(&testtypes.CodecStruct{}); err == nil {
+		t.Error("Testing serialiser output starting with a zero byte. Expected an error saving")
+	}
+}
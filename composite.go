@@ -0,0 +1,183 @@
+package tormenta
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Composite index format
+// i:root:indexname:value1(0x00)value2(0x00)...:entityID
+// i:order:CustomerStatus:(customerID)(0x00)paid:324ds-3werwf-234wef-23wef
+//
+// Composite indexes are declared on the type, either with a tag
+// (usually on the embedded Model) of the form `tormenta:"index=CustomerStatus:CustomerID,Status"`,
+// or by implementing CompositeIndexer.  Several can be declared by repeating the tag.
+// Composite index names share the key space with field indexes, so they can't be the name of a field.
+
+const (
+	tormentaTagIndex = "index"
+
+	// Separates the index name from its fields in the tag
+	compositeIndexNameSeparator = ":"
+
+	// Separates the fields of the index in the tag
+	compositeIndexFieldSeparator = ","
+
+	// Separates the encoded values in the key.
+	// Since it sorts before every other byte, keys are ordered
+	// by the first field, then the second, and so on
+	compositeValueSeparator = "\x00"
+
+	// One past the value separator - the upper bound of everything starting with a given set of values
+	compositeValueUpperBound = "\x01"
+)
+
+// CompositeIndex is an index over several fields at once,
+// which can be searched with MatchComposite and RangeComposite
+type CompositeIndex struct {
+	Name   string
+	Fields []string
+}
+
+// CompositeIndexer can be implemented to declare composite indexes in code,
+// as an alternative to the `tormenta:"index=Name:Field1,Field2"` tag
+type CompositeIndexer interface {
+	Indexes() []CompositeIndex
+}
+
+// compositeIndexes lists all the composite indexes declared for an entity
+func compositeIndexes(entity Record) (indexes []CompositeIndex) {
+	v := recordValue(entity)
+
+	for i := 0; i < v.NumField(); i++ {
+		for _, tag := range getTormentaTags(v.Type().Field(i)) {
+			kv := strings.SplitN(strings.TrimSpace(tag), tagValueSeparator, 2)
+			if len(kv) != 2 || kv[0] != tormentaTagIndex {
+				continue
+			}
+
+			// Per-field index options don't name any fields, so they aren't composite indexes
+			definition := strings.SplitN(kv[1], compositeIndexNameSeparator, 2)
+			if len(definition) != 2 {
+				continue
+			}
+
+			index := CompositeIndex{Name: strings.TrimSpace(definition[0])}
+			for _, field := range strings.Split(definition[1], compositeIndexFieldSeparator) {
+				index.Fields = append(index.Fields, strings.TrimSpace(field))
+			}
+
+			indexes = append(indexes, index)
+		}
+	}
+
+	if indexer, ok := entity.(CompositeIndexer); ok {
+		indexes = append(indexes, indexer.Indexes()...)
+	}
+
+	return
+}
+
+// checkCompositeIndexes makes sure that no composite index has the same name as a field.
+// Composite and field index keys share the same key space, so their keys would get mixed up
+func checkCompositeIndexes(entity Record) error {
+	t := recordValue(entity).Type()
+	for _, index := range compositeIndexes(entity) {
+		if _, ok := t.FieldByName(index.Name); ok {
+			return fmt.Errorf(ErrCompositeIndexName, index.Name, KeyRootString(entity))
+		}
+	}
+
+	return nil
+}
+
+func findCompositeIndex(entity Record, indexName string) (CompositeIndex, error) {
+	if err := checkCompositeIndexes(entity); err != nil {
+		return CompositeIndex{}, err
+	}
+
+	for _, index := range compositeIndexes(entity) {
+		if index.Name == indexName {
+			return index, nil
+		}
+	}
+
+	return CompositeIndex{}, fmt.Errorf(ErrCompositeIndexNotFound, indexName)
+}
+
+// compositeIndexKeys builds the keys for all the composite indexes of an entity.
+// An index naming a field that doesn't exist can't be built, so it is left out
+func compositeIndexKeys(entity Record) (keys [][]byte) {
+	v := recordValue(entity)
+	root := KeyRoot(entity)
+	id := entity.GetID()
+
+	for _, index := range compositeIndexes(entity) {
+		values := make([][]byte, len(index.Fields))
+		complete := true
+
+		for i, fieldName := range index.Fields {
+			fieldType, ok := v.Type().FieldByName(fieldName)
+			if !ok {
+				complete = false
+				break
+			}
+
+			values[i] = encodeIndexValue(v.FieldByIndex(fieldType.Index).Interface(), fieldIndexMode(fieldType))
+		}
+
+		if complete {
+			keys = append(keys, newIndexMatchKey(root, []byte(index.Name), joinCompositeValues(values), id).bytes())
+		}
+	}
+
+	return
+}
+
+// encodeCompositeValues encodes user provided values for the leading fields of a composite index,
+// in the same way as they are encoded when indexing
+func encodeCompositeValues(entity Record, index CompositeIndex, values []interface{}) ([]byte, error) {
+	if len(values) > len(index.Fields) {
+		return nil, fmt.Errorf(ErrTooManyCompositeValues, index.Name, len(index.Fields), len(values))
+	}
+
+	encoded := make([][]byte, len(values))
+	for i, value := range values {
+		kind, err := fieldKind(entity, index.Fields[i])
+		if err != nil {
+			return nil, err
+		}
+
+		if encoded[i], err = encodeQueryValue(value, kind, targetIndexMode(entity, index.Fields[i])); err != nil {
+			return nil, err
+		}
+	}
+
+	return joinCompositeValues(encoded), nil
+}
+
+func joinCompositeValues(values [][]byte) []byte {
+	return bytes.Join(values, []byte(compositeValueSeparator))
+}
+
+// compositeFilter sets up a filter on a composite index.
+// Encoded values are passed to the filter as strings, which unlike byte slices can be compared
+func (q *Query) compositeFilter(indexName string, start, end []byte) {
+	f := filter{
+		indexName:   toIndexName(indexName),
+		indexKind:   reflect.Invalid,
+		isComposite: true,
+	}
+
+	if start != nil {
+		f.start = string(start)
+	}
+
+	if end != nil {
+		f.end = string(end)
+	}
+
+	q.addFilter(f)
+}
@@ -0,0 +1,19 @@
+package tormenta
+
+import "context"
+
+// ctxErr reports, without blocking, whether a standard library context
+// has been cancelled or has passed its deadline.
+// A nil context is treated as one that never ends
+func ctxErr(goCtx context.Context) error {
+	if goCtx == nil {
+		return nil
+	}
+
+	select {
+	case <-goCtx.Done():
+		return goCtx.Err()
+	default:
+		return nil
+	}
+}
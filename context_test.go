@@ -0,0 +1,94 @@
+package tormenta_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jpincas/tormenta"
+	"github.com/jpincas/tormenta/testtypes"
+)
+
+func Test_Context_Cancelled(t *testing.T) {
+	db, _ := tormenta.OpenTestWithOptions("data/tests", testDBOptions)
+	defer db.Close()
+
+	var toSave []tormenta.Record
+	for i := 0; i < 100; i++ {
+		toSave = append(toSave, &testtypes.FullStruct{IntField: i})
+	}
+	db.Save(toSave...)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	testCases := []struct {
+		testName string
+		query    *tormenta.Query
+	}{
+		{"basic query", db.Find(&[]testtypes.FullStruct{})},
+		{"index filter", db.Find(&[]testtypes.FullStruct{}).Range("IntField", 10, 50)},
+		{"order by", db.Find(&[]testtypes.FullStruct{}).OrderBy("IntField")},
+	}
+
+	for _, testCase := range testCases {
+		n, err := testCase.query.RunCtx(ctx)
+		if err != context.Canceled {
+			t.Errorf("Testing %s with cancelled context. Expected context.Canceled, got %v", testCase.testName, err)
+		}
+
+		if n != 0 {
+			t.Errorf("Testing %s with cancelled context. Expected 0 results, got %v", testCase.testName, n)
+		}
+	}
+
+	if _, err := db.Find(&[]testtypes.FullStruct{}).CountCtx(ctx); err != context.Canceled {
+		t.Errorf("Testing count with cancelled context. Expected context.Canceled, got %v", err)
+	}
+
+	var sum int
+	if _, err := db.Find(&[]testtypes.FullStruct{}).SumCtx(ctx, &sum, "IntField"); err != context.Canceled {
+		t.Errorf("Testing sum with cancelled context. Expected context.Canceled, got %v", err)
+	}
+
+	first := toSave[0].(*testtypes.FullStruct)
+	if found, err := db.GetCtx(ctx, &testtypes.FullStruct{}, first.ID); err != context.Canceled || found {
+		t.Errorf("Testing get with cancelled context. Expected context.Canceled and not found, got %v / %v", err, found)
+	}
+
+	var results []testtypes.FullStruct
+	if _, err := db.GetIDsCtx(ctx, &results, first.ID); err != context.Canceled {
+		t.Errorf("Testing get IDs with cancelled context. Expected context.Canceled, got %v", err)
+	}
+
+	if n, err := db.SaveCtx(ctx, &testtypes.FullStruct{}); err != context.Canceled || n != 0 {
+		t.Errorf("Testing save with cancelled context. Expected context.Canceled and 0 saved, got %v / %v", err, n)
+	}
+
+	// Nothing should have been saved
+	if n, _ := db.Find(&results).Count(); n != 100 {
+		t.Errorf("Testing save with cancelled context. Expected 100 records, got %v", n)
+	}
+}
+
+func Test_Context_Live(t *testing.T) {
+	db, _ := tormenta.OpenTestWithOptions("data/tests", testDBOptions)
+	defer db.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	fullStruct := testtypes.FullStruct{IntField: 1}
+	if n, err := db.SaveCtx(ctx, &fullStruct); err != nil || n != 1 {
+		t.Errorf("Testing save with live context. Expected 1 saved and no error, got %v / %v", n, err)
+	}
+
+	if found, err := db.GetCtx(ctx, &testtypes.FullStruct{}, fullStruct.ID); err != nil || !found {
+		t.Errorf("Testing get with live context. Expected found and no error, got %v / %v", found, err)
+	}
+
+	var results []testtypes.FullStruct
+	if n, err := db.Find(&results).Match("IntField", 1).RunCtx(ctx); err != nil || n != 1 {
+		t.Errorf("Testing query with live context. Expected 1 result and no error, got %v / %v", n, err)
+	}
+}
@@ -12,13 +12,44 @@ import (
 type DB struct {
 	KV      *badger.DB
 	Options Options
+
+	// txn is set when the DB has been bound to an explicit transaction
+	// (see Update/View), in which case all operations share it
+	txn *badger.Txn
+
+	// feed sends out changes to subscribers (see Subscribe/Watch).
+	// It is shared by all copies of the DB
+	feed *changeFeed
+
+	// middleware wraps all operations (see Use).
+	// It is shared by all copies of the DB
+	middleware *middlewareChain
+
+	// registry holds the registered entity types (see Register).
+	// It is shared by all copies of the DB
+	registry *typeRegistry
+
+	// journal remembers the versions bumped by each transaction,
+	// so they can be put back if it doesn't commit.
+	// It is shared by all copies of the DB
+	journal *versionJournal
 }
 
 type Options struct {
 	SerialiseFunc   func(interface{}) ([]byte, error)
 	UnserialiseFunc func([]byte, interface{}) error
-	BadgerOptions   badger.Options
-	DebugMode       bool
+
+	// Codec, if set, is used to store records in place of SerialiseFunc,
+	// and is noted with each value (see Codec).  Values stored with SerialiseFunc,
+	// or with another codec, can still be read, so a DB can be switched to a codec,
+	// or from one codec to another, gradually - records are stored with the new codec
+	// as they are saved.  Entity types can also have codecs of their own (see CodecSelector).
+	// Values stored with a codec start with a zero byte, so SerialiseFunc must never return
+	// a value which starts with one (JSON, protobuf, gob and msgpack never do)
+	Codec Codec
+
+	BadgerOptions badger.Options
+	DebugMode     bool
 }
 
 var DefaultOptions = Options{
@@ -98,15 +129,79 @@ func (db DB) Close() error {
 
 func openDB(badgerDB *badger.DB, options Options) (*DB, error) {
 	return &DB{
-		KV:      badgerDB,
-		Options: options,
+		KV:         badgerDB,
+		Options:    options,
+		feed:       newChangeFeed(),
+		middleware: &middlewareChain{},
+		registry:   newTypeRegistry(),
+		journal:    newVersionJournal(),
 	}, nil
 }
 
+// view runs fn in a read-only transaction,
+// or in the transaction the DB is bound to, if there is one
+func (db DB) view(fn func(txn *badger.Txn) error) error {
+	if db.txn != nil {
+		return fn(db.txn)
+	}
+
+	return db.KV.View(fn)
+}
+
+// update runs fn in a read-write transaction,
+// or in the transaction the DB is bound to, if there is one.
+// In the latter case, committing is left to the owner of the transaction.
+// Changes made in the transaction are sent to subscribers once it has committed,
+// and if it doesn't commit, the versions of the entities it saved are put back
+func (db DB) update(fn func(txn *badger.Txn) error) error {
+	if db.txn != nil {
+		return fn(db.txn)
+	}
+
+	var changes []Change
+	var saved []savedVersion
+	if err := db.KV.Update(func(txn *badger.Txn) error {
+		db.feed.begin(txn)
+		db.journal.begin(txn)
+		defer func() {
+			changes = db.feed.end(txn)
+			saved = db.journal.end(txn)
+		}()
+
+		return fn(txn)
+	}); err != nil {
+		restoreVersions(saved)
+		return err
+	}
+
+	db.feed.publish(changes)
+	return nil
+}
+
+// withTxn returns a copy of the DB bound to the given transaction
+func (db DB) withTxn(txn *badger.Txn) DB {
+	db.txn = txn
+	return db
+}
+
+// unserialise decodes a stored value with the codec it was stored with
 func (db DB) unserialise(val []byte, entity interface{}) error {
-	return db.Options.UnserialiseFunc(val, entity)
+	codec, data, err := splitValue(val)
+	if err != nil {
+		return err
+	}
+
+	return db.unmarshal(codec, data, entity)
 }
 
-func (db DB) serialise(entity interface{}) ([]byte, error) {
-	return db.Options.SerialiseFunc(entity)
+// serialise encodes an entity for storage, without the fields that aren't to be saved
+func (db DB) serialise(entity Record) ([]byte, error) {
+	e := recordValue(entity)
+
+	codec := db.codecFor(entity)
+	if codec == nil {
+		return db.marshal(nil, removeSkippedFields(e))
+	}
+
+	return db.marshal(codec, withoutSkippedFields(e))
 }
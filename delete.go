@@ -11,7 +11,16 @@ const (
 	ErrRecordNotFound = "Record with ID %v was not found"
 )
 
+// Delete deletes an entity, either according to the ID set on the entity,
+// or using a separately specified ID (optional, takes priority).
+// If the DB is bound to an explicit transaction (see Update), that transaction is used
 func (db DB) Delete(entity Record, ids ...gouuidv6.UUID) error {
+	return db.update(func(txn *badger.Txn) error {
+		return db.delete(txn, entity, ids...)
+	})
+}
+
+func (db DB) delete(txn *badger.Txn, entity Record, ids ...gouuidv6.UUID) error {
 	// If a separate entity ID has been specified then use it
 	if len(ids) > 0 {
 		entity.SetID(ids[0])
@@ -21,29 +30,76 @@ func (db DB) Delete(entity Record, ids ...gouuidv6.UUID) error {
 	// Its a good sanity check to make sure it really exists,
 	// but more importantly we're going to need to deindex it,
 	// so we'll need it current state
-	if found, err := db.Get(entity); err != nil {
+	if found, err := db.get(txn, entity, noCTX); err != nil {
 		return err
 	} else if !found {
 		return fmt.Errorf(ErrRecordNotFound, entity.GetID())
 	}
 
-	err := db.KV.Update(func(txn *badger.Txn) error {
-		if err := deleteRecord(txn, entity); err != nil {
+	return db.remove(txn, entity)
+}
+
+// remove deletes an entity which has already been retrieved,
+// together with its index keys
+func (db DB) remove(txn *badger.Txn, entity Record) error {
+	op := Operation{
+		Type:    DeleteOperation,
+		KeyRoot: KeyRootString(entity),
+		Entity:  entity,
+	}
+
+	return db.run(op, func(Operation) error {
+		return db.removeOne(txn, entity)
+	})
+}
+
+func (db DB) removeOne(txn *badger.Txn, entity Record) error {
+	// Predelete trigger
+	// It gets a DB bound to this transaction, and any records it returns
+	// are deleted after this one, in the same transaction
+	moreRecordsToDelete, err := preDelete(db.withTxn(txn), entity)
+	if err != nil {
+		return err
+	}
+
+	if err := deleteRecord(txn, entity); err != nil {
+		return err
+	}
+
+	if err := deIndex(txn, entity); err != nil {
+		return err
+	}
+
+	db.feed.record(txn, func() Change {
+		return newChange(Deleted, entity, copyRecord(entity), nil)
+	})
+
+	for _, record := range moreRecordsToDelete {
+		if found, err := db.get(txn, record, noCTX); err != nil {
 			return err
+		} else if !found {
+			continue
 		}
 
-		if err := deIndex(txn, entity); err != nil {
+		if err := db.remove(txn, record); err != nil {
 			return err
 		}
+	}
 
-		return nil
-	})
-
-	return err
+	// Post delete trigger, once everything has been deleted,
+	// so that it doesn't run if deleting a related record fails
+	return postDelete(entity)
 }
 
 func deleteRecord(txn *badger.Txn, entity Record) error {
 	root := KeyRoot(entity)
+
+	if isSoftDeleted(entity) {
+		if err := txn.Delete(softDeleteKey(root, entity.GetID())); err != nil {
+			return err
+		}
+	}
+
 	key := newContentKey(root, entity.GetID()).bytes()
 	return txn.Delete(key)
 }
@@ -3,6 +3,7 @@ package tormenta_test
 import (
 	"testing"
 
+	"github.com/jpincas/gouuidv6"
 	"github.com/jpincas/tormenta"
 	"github.com/jpincas/tormenta/testtypes"
 )
@@ -74,3 +75,111 @@ func Test_Delete_SeparateID(t *testing.T) {
 		t.Error("Testing delete. Supposedly deleted fullStruct found on 2nd get")
 	}
 }
+
+func Test_Delete_Triggers(t *testing.T) {
+	db, _ := tormenta.OpenTestWithOptions("data/tests", testDBOptions)
+	defer db.Close()
+
+	child1 := testtypes.MiniStruct{}
+	child2 := testtypes.MiniStruct{}
+	unrelated := testtypes.MiniStruct{}
+	db.Save(&child1, &child2, &unrelated)
+
+	// A child that has already gone should just be skipped
+	goneChild := testtypes.MiniStruct{}
+	db.Save(&goneChild)
+	db.Delete(&goneChild)
+
+	parent := testtypes.DeleteTriggerStruct{
+		ChildIDs: []gouuidv6.UUID{child1.ID, child2.ID, goneChild.ID},
+	}
+	db.Save(&parent)
+
+	toDelete := testtypes.DeleteTriggerStruct{}
+	if err := db.Delete(&toDelete, parent.ID); err != nil {
+		t.Fatalf("Testing delete with triggers. Got error %v", err)
+	}
+
+	if !toDelete.IsDeleted {
+		t.Error("Testing delete with triggers. Post delete trigger did not run")
+	}
+
+	var results []testtypes.MiniStruct
+	if n, _ := db.Find(&results).Run(); n != 1 || results[0].ID != unrelated.ID {
+		t.Errorf("Testing delete with triggers. Expected only the unrelated record to remain, got %v records", n)
+	}
+}
+
+func Test_Delete_Triggers_Veto(t *testing.T) {
+	db, _ := tormenta.OpenTestWithOptions("data/tests", testDBOptions)
+	defer db.Close()
+
+	child := testtypes.MiniStruct{}
+	db.Save(&child)
+
+	parent := testtypes.DeleteTriggerStruct{
+		ShouldBlockDelete: true,
+		ChildIDs:          []gouuidv6.UUID{child.ID},
+	}
+	db.Save(&parent)
+
+	if err := db.Delete(&testtypes.DeleteTriggerStruct{}, parent.ID); err == nil {
+		t.Error("Testing vetoed delete. Expected an error but did not get one")
+	}
+
+	// The same applies to deletes by query
+	if n, err := db.Find(&[]testtypes.DeleteTriggerStruct{}).Delete(); err == nil || n != 0 {
+		t.Errorf("Testing vetoed delete by query. Expected an error and nothing deleted, got %v / %v", err, n)
+	}
+
+	if found, _ := db.Get(&testtypes.DeleteTriggerStruct{}, parent.ID); !found {
+		t.Error("Testing vetoed delete. Record should still exist")
+	}
+
+	if found, _ := db.Get(&testtypes.MiniStruct{}, child.ID); !found {
+		t.Error("Testing vetoed delete. Related record should still exist")
+	}
+}
+
+// deleteParentStruct deletes a related record along with itself
+type deleteParentStruct struct {
+	tormenta.Model
+
+	ChildID   gouuidv6.UUID
+	IsDeleted bool `tormenta:"-"`
+}
+
+func (s *deleteParentStruct) PreDelete(db tormenta.DB) ([]tormenta.Record, error) {
+	child := &testtypes.DeleteTriggerStruct{}
+	child.SetID(s.ChildID)
+	return []tormenta.Record{child}, nil
+}
+
+func (s *deleteParentStruct) PostDelete() error {
+	s.IsDeleted = true
+	return nil
+}
+
+func Test_Delete_Triggers_RelatedFails(t *testing.T) {
+	db, _ := tormenta.OpenTestWithOptions("data/tests", testDBOptions)
+	defer db.Close()
+
+	// The related record refuses to be deleted
+	child := testtypes.DeleteTriggerStruct{ShouldBlockDelete: true}
+	db.Save(&child)
+
+	parent := deleteParentStruct{ChildID: child.ID}
+	db.Save(&parent)
+
+	if err := db.Delete(&parent); err == nil {
+		t.Error("Testing delete with a failing related delete. Expected an error but did not get one")
+	}
+
+	if parent.IsDeleted {
+		t.Error("Testing delete with a failing related delete. Post delete trigger should not have run")
+	}
+
+	if found, _ := db.Get(&deleteParentStruct{}, parent.ID); !found {
+		t.Error("Testing delete with a failing related delete. Record should still exist")
+	}
+}
@@ -0,0 +1,206 @@
+package tormenta
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/dgraph-io/badger"
+	"github.com/jpincas/gouuidv6"
+)
+
+const (
+	ErrDumpLineInvalid = "Dump line %v is invalid: %v"
+)
+
+// dumpLine is a single record in a dump.
+// Records are written as they are stored, without being unserialised,
+// so with the default JSON serialiser they go in Data.
+// Anything that isn't valid JSON (e.g. from a custom serialiser) goes in Raw instead,
+// which ends up base64 encoded.  Records stored with a codec have its name in Codec,
+// and their data doesn't include the codec identifier
+type dumpLine struct {
+	Type      string          `json:"type"`
+	ID        gouuidv6.UUID   `json:"id"`
+	Codec     string          `json:"codec,omitempty"`
+	Data      json.RawMessage `json:"data,omitempty"`
+	Raw       []byte          `json:"raw,omitempty"`
+	ExpiresAt uint64          `json:"expiresAt,omitempty"`
+}
+
+// Dump writes the stored records of the given entity types (or of every type in the DB, if none are given)
+// to w as newline delimited JSON, one record per line, e.g.
+// {"type":"order","id":"...","data":{...}}
+// Records are read straight from the content keys, so the types don't need to be registered,
+// and soft deleted records are included.  Index keys aren't dumped - RestoreDump rebuilds them.
+// Records are read in chunks, each in its own transaction, so saves made while the dump
+// is running may or may not be included
+func (db DB) Dump(w io.Writer, entities ...Record) error {
+	var prefixes [][]byte
+	for _, entity := range entities {
+		prefixes = append(prefixes, typePrefix(contentKeyPrefix, KeyRoot(entity)))
+	}
+
+	if len(prefixes) == 0 {
+		prefixes = [][]byte{[]byte(contentKeyPrefix + keySeparator)}
+	}
+
+	buffered := bufio.NewWriter(w)
+	encoder := json.NewEncoder(buffered)
+
+	for _, prefix := range prefixes {
+		if err := db.scanPrefix(prefix, true, func(chunk []rawItem) error {
+			for _, item := range chunk {
+				line := dumpLine{
+					Type:      string(bytes.Split(item.key, []byte(keySeparator))[1]),
+					ID:        extractID(item.key),
+					ExpiresAt: item.expiresAt,
+				}
+
+				codec, data, err := splitValue(item.value)
+				if err != nil {
+					return err
+				}
+
+				if codec != nil {
+					line.Codec = codec.Name()
+				}
+
+				if json.Valid(data) {
+					line.Data = data
+				} else {
+					line.Raw = data
+				}
+
+				if err := encoder.Encode(line); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		}); err != nil {
+			return err
+		}
+	}
+
+	return buffered.Flush()
+}
+
+// RestoreDump reads a dump written by Dump and stores its records under their original IDs,
+// overwriting any records that already have them, and returns the number of records restored.
+// Records of registered types (see Register) then have their indexes rebuilt
+// and their soft deleted status set; records of other types are restored as they are,
+// and can be indexed later with RebuildIndexes.
+// Records are written in chunks, each in its own transaction, so a dump that fails part way through
+// leaves the records before the failure restored.
+// (It is named RestoreDump as Restore brings back soft deleted entities)
+func (db DB) RestoreDump(r io.Reader) (int, error) {
+	decoder := json.NewDecoder(r)
+
+	var chunk []dumpLine
+	restored := 0
+	var keyRoots []string
+	seen := map[string]bool{}
+
+	for lineNumber := 1; ; lineNumber++ {
+		var line dumpLine
+		err := decoder.Decode(&line)
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return restored, fmt.Errorf(ErrDumpLineInvalid, lineNumber, err)
+		}
+
+		if line.Type == "" || line.ID.IsNil() {
+			return restored, fmt.Errorf(ErrDumpLineInvalid, lineNumber, "type and id are required")
+		}
+
+		if _, ok := codecByName(line.Codec); line.Codec != "" && !ok {
+			return restored, fmt.Errorf(ErrDumpLineInvalid, lineNumber, fmt.Sprintf(ErrCodecNotRegistered, line.Codec))
+		}
+
+		if !seen[line.Type] {
+			seen[line.Type] = true
+			keyRoots = append(keyRoots, line.Type)
+		}
+
+		chunk = append(chunk, line)
+		if len(chunk) == maintenanceChunkSize {
+			if err := db.restoreChunk(chunk); err != nil {
+				return restored, err
+			}
+
+			restored += len(chunk)
+			chunk = nil
+		}
+	}
+
+	if len(chunk) > 0 {
+		if err := db.restoreChunk(chunk); err != nil {
+			return restored, err
+		}
+
+		restored += len(chunk)
+	}
+
+	for _, keyRoot := range keyRoots {
+		if entity, ok := db.registeredType(keyRoot); ok {
+			if _, err := db.RebuildIndexes(entity, nil); err != nil {
+				return restored, err
+			}
+		}
+	}
+
+	return restored, nil
+}
+
+// restoreChunk writes the records of a chunk of a dump in a single transaction
+func (db DB) restoreChunk(chunk []dumpLine) error {
+	return db.KV.Update(func(txn *badger.Txn) error {
+		for _, line := range chunk {
+			root := []byte(line.Type)
+			data := []byte(line.Data)
+			if len(line.Raw) > 0 {
+				data = line.Raw
+			}
+
+			// No codec means the record was stored with SerialiseFunc
+			codec, _ := codecByName(line.Codec)
+			value, err := joinValue(codec, data)
+			if err != nil {
+				return err
+			}
+
+			item := rawItem{
+				key:       newContentKey(root, line.ID).bytes(),
+				value:     value,
+				expiresAt: line.ExpiresAt,
+			}
+
+			if err := setEntry(txn, item.key, item.value, item.expiresAt); err != nil {
+				return err
+			}
+
+			entity, ok := db.registeredType(line.Type)
+			if !ok {
+				continue
+			}
+
+			record, err := db.decodeContent(entity, item)
+			if err != nil {
+				return err
+			}
+
+			// The record being overwritten may have been soft deleted
+			if model, ok := recordModel(record); ok {
+				if err := syncSoftDeleteMarker(txn, root, model, true, item.expiresAt); err != nil {
+					return err
+				}
+			}
+		}
+
+		return nil
+	})
+}
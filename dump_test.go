@@ -0,0 +1,98 @@
+package tormenta_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/jpincas/tormenta"
+	"github.com/jpincas/tormenta/testtypes"
+)
+
+func Test_DumpRestore(t *testing.T) {
+	db, _ := tormenta.OpenTestWithOptions("data/tests", testDBOptions)
+	defer db.Close()
+
+	kept := testtypes.MiniStruct{IntField: 1, StringField: "jon"}
+	deleted := testtypes.MiniStruct{IntField: 2, StringField: "jon"}
+	other := testtypes.FullStruct{IntField: 3}
+	db.Save(&kept, &deleted, &other)
+	db.SoftDelete(&deleted, deleted.ID)
+
+	buf := bytes.Buffer{}
+	if err := db.Dump(&buf); err != nil {
+		t.Fatalf("Testing dump. Got error %v", err)
+	}
+
+	if lines := strings.Count(buf.String(), "\n"); lines != 3 {
+		t.Errorf("Testing dump. Expected 3 lines, got %v", lines)
+	}
+
+	// Restore into a fresh DB
+	restoreDB, _ := tormenta.OpenTestWithOptions("data/tests-restore", testDBOptions)
+	defer restoreDB.Close()
+	restoreDB.Register(&testtypes.MiniStruct{})
+
+	n, err := restoreDB.RestoreDump(&buf)
+	if err != nil {
+		t.Fatalf("Testing restore. Got error %v", err)
+	}
+
+	if n != 3 {
+		t.Errorf("Testing restore. Expected 3 records restored, got %v", n)
+	}
+
+	result := testtypes.MiniStruct{}
+	if found, _ := restoreDB.Get(&result, kept.ID); !found || result.StringField != "jon" || result.IntField != 1 {
+		t.Errorf("Testing restore. Expected to get the record back with its original ID, got %v (found %v)", result, found)
+	}
+
+	// Registered types are reindexed, and keep their soft deleted status
+	if n, _ := restoreDB.Find(&[]testtypes.MiniStruct{}).Match("StringField", "jon").Count(); n != 1 {
+		t.Errorf("Testing restore. Expected 1 live indexed record, got %v", n)
+	}
+
+	if n, _ := restoreDB.Find(&[]testtypes.MiniStruct{}).Match("StringField", "jon").OnlyDeleted().Count(); n != 1 {
+		t.Errorf("Testing restore. Expected 1 soft deleted record, got %v", n)
+	}
+
+	// Other types are restored, but not indexed
+	fullStruct := testtypes.FullStruct{}
+	if found, _ := restoreDB.Get(&fullStruct, other.ID); !found {
+		t.Error("Testing restore. Expected the unregistered type to be restored")
+	}
+
+	if n, _ := restoreDB.Find(&[]testtypes.FullStruct{}).Match("IntField", 3).Count(); n != 0 {
+		t.Errorf("Testing restore. Expected the unregistered type not to be indexed, got %v results", n)
+	}
+}
+
+func Test_Dump_Types(t *testing.T) {
+	db, _ := tormenta.OpenTestWithOptions("data/tests", testDBOptions)
+	defer db.Close()
+
+	db.Save(&testtypes.MiniStruct{}, &testtypes.MiniStruct{}, &testtypes.FullStruct{})
+
+	buf := bytes.Buffer{}
+	db.Dump(&buf, &testtypes.FullStruct{})
+
+	if lines := strings.Count(buf.String(), "\n"); lines != 1 || !strings.Contains(buf.String(), `"type":"fullstruct"`) {
+		t.Errorf("Testing dump of a single type. Expected 1 fullstruct line, got %s", buf.String())
+	}
+}
+
+func Test_RestoreDump_Invalid(t *testing.T) {
+	db, _ := tormenta.OpenTestWithOptions("data/tests", testDBOptions)
+	defer db.Close()
+
+	testCases := []string{
+		`{"type":"ministruct"`,
+		`{"data":{}}`,
+	}
+
+	for _, testCase := range testCases {
+		if _, err := db.RestoreDump(strings.NewReader(testCase)); err == nil {
+			t.Errorf("Testing invalid dump %s. Expected an error, got none", testCase)
+		}
+	}
+}
@@ -7,4 +7,9 @@ const (
 	ErrBlankInputStartsWithQuery = "Blank string is not valid input for 'starts with' query"
 	ErrFieldCouldNotBeFound      = "Field %s could not be found"
 	ErrIndexTypeBool             = "%v could not be interpreted as true/false"
+	ErrFieldCannotBeSet          = "Field %s cannot be set directly"
+	ErrFieldValueWrongType       = "Field %s is of type %s - %v (%T) cannot be assigned to it"
+	ErrCompositeIndexNotFound    = "Composite index %s could not be found"
+	ErrTooManyCompositeValues    = "Composite index %s has %v field(s), but %v values were given"
+	ErrCompositeIndexName        = "Composite index %s of %s has the same name as a field - composite indexes need names of their own"
 )
@@ -1,7 +1,10 @@
 package tormenta
 
 import (
+	"fmt"
 	"reflect"
+	"strings"
+	"time"
 )
 
 //MapFields returns a map keyed by fieldname with the value of the field as an interface
@@ -56,3 +59,112 @@ func structFields(entity interface{}) (fields []string) {
 
 	return
 }
+
+// setFields sets fields on a record by name, converting values where it is safe to do so
+// (e.g. a float64 decoded from JSON into an int field).  Nested fields can be
+// reached with dot notation.  The fields of the tormenta Model can't be set this way
+func setFields(record Record, fields map[string]interface{}) error {
+	for fieldName, value := range fields {
+		if err := setField(record, fieldName, value); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func setField(record Record, fieldName string, value interface{}) error {
+	path := strings.Split(fieldName, ".")
+
+	if _, isModelField := typeModel.FieldByName(path[0]); isModelField || path[0] == "Model" {
+		return fmt.Errorf(ErrFieldCannotBeSet, fieldName)
+	}
+
+	field := recordValue(record)
+	for _, name := range path {
+		if field.Kind() != reflect.Struct {
+			return fmt.Errorf(ErrFieldCouldNotBeFound, fieldName)
+		}
+
+		field = field.FieldByName(name)
+		if !field.IsValid() {
+			return fmt.Errorf(ErrFieldCouldNotBeFound, fieldName)
+		}
+	}
+
+	if !field.CanSet() {
+		return fmt.Errorf(ErrFieldCannotBeSet, fieldName)
+	}
+
+	converted, ok := convertValue(value, field.Type())
+	if !ok {
+		return fmt.Errorf(ErrFieldValueWrongType, fieldName, field.Type(), value, value)
+	}
+
+	field.Set(converted)
+	return nil
+}
+
+// convertValue tries to turn a value into the given type.
+// Numbers are only converted if nothing is lost along the way
+func convertValue(value interface{}, t reflect.Type) (reflect.Value, bool) {
+	if value == nil {
+		return reflect.Zero(t), true
+	}
+
+	v := reflect.ValueOf(value)
+	if v.Type().AssignableTo(t) {
+		return v, true
+	}
+
+	// Times are accepted as RFC3339 strings
+	if t == typeTime {
+		if s, ok := value.(string); ok {
+			if parsed, err := time.Parse(time.RFC3339, s); err == nil {
+				return reflect.ValueOf(parsed), true
+			}
+		}
+
+		return reflect.Value{}, false
+	}
+
+	if IsNumberKind(v.Kind()) && IsNumberKind(t.Kind()) {
+		// A round trip back to the original type, and the sign, tell us if anything was lost
+		converted := v.Convert(t)
+		if converted.Convert(v.Type()).Interface() != v.Interface() || isNegative(converted) != isNegative(v) {
+			return reflect.Value{}, false
+		}
+
+		return converted, true
+	}
+
+	// Same underlying kind, e.g. a string into a 'defined' string type
+	if v.Kind() == t.Kind() && v.Type().ConvertibleTo(t) {
+		return v.Convert(t), true
+	}
+
+	return reflect.Value{}, false
+}
+
+// IsNumberKind tells whether a kind is one of the integer or floating point kinds
+func IsNumberKind(k reflect.Kind) bool {
+	switch k {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+
+	return false
+}
+
+func isNegative(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() < 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() < 0
+	}
+
+	return false
+}
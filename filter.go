@@ -1,6 +1,7 @@
 package tormenta
 
 import (
+	"context"
 	"reflect"
 	"time"
 
@@ -26,6 +27,12 @@ type filter struct {
 	// Limit number of returned results
 	limit int
 
+	// Cancellation context
+	goCtx context.Context
+
+	// Treatment of soft deleted records
+	deleted deletedMode
+
 	// Offet - start returning results N entities from the beginning
 	// offsetCounter used to track the offset
 	offset, offsetCounter int
@@ -42,9 +49,16 @@ type filter struct {
 
 	indexKind reflect.Kind
 
+	// How strings were normalised when the field was indexed
+	indexMode indexMode
+
 	// Is this a 'starts with' index query
 	isStartsWithQuery bool
 
+	// Is this a search on a composite index,
+	// in which case start and end are already encoded
+	isComposite bool
+
 	// Ranges and comparision key
 	seekFrom, validTo, compareTo []byte
 
@@ -129,12 +143,12 @@ func (f *filter) setRanges() error {
 		f.from = tempTo
 	}
 
-	startBytes, err := interfaceToBytesWithOverride(f.start, f.indexKind)
+	startBytes, err := f.valueBytes(f.start)
 	if err != nil {
 		return err
 	}
 
-	endBytes, err := interfaceToBytesWithOverride(f.end, f.indexKind)
+	endBytes, err := f.valueBytes(f.end)
 	if err != nil {
 		return err
 	}
@@ -164,6 +178,18 @@ func (f *filter) setRanges() error {
 	return nil
 }
 
+func (f filter) valueBytes(value interface{}) ([]byte, error) {
+	if f.isComposite {
+		if value == nil {
+			return []byte{}, nil
+		}
+
+		return []byte(value.(string)), nil
+	}
+
+	return encodeQueryValue(value, f.indexKind, f.indexMode)
+}
+
 func (f filter) endIteration(it *badger.Iterator, noIDsSoFar int) bool {
 	if it.ValidForPrefix(f.validTo) {
 		if f.isLimitMet(noIDsSoFar) || f.isEndOfRange(it) {
@@ -216,30 +242,43 @@ func (f *filter) queryIDs(txn *badger.Txn) (ids idList, err error) {
 
 	f.reset()
 
+	deleted := newDeletedFilter(txn, f.keyRoot, f.deleted)
+
 	it := txn.NewIterator(f.getIteratorOptions())
 	defer it.Close()
 
 	for it.Seek(f.seekFrom); f.endIteration(it, ids.length()); it.Next() {
+		if err = ctxErr(f.goCtx); err != nil {
+			return nil, err
+		}
+
 		// If this is a 'range index' type Query
 		// that ALSO has a date range, the procedure is a little more complicated
 		// compared to an exact index match.
 		// Since the start/end points of the iteration focus on the index, e.g. E-J (alphabetical index)
 		// we need to manually check all the keys and reject those that don't fit the date range
+		id := extractID(it.Item().Key())
 		if !f.isExactIndexMatchSearch() {
-			key := extractID(it.Item().Key())
-			if keyIsOutsideDateRange(key, f.from, f.to) {
+			if keyIsOutsideDateRange(id, f.from, f.to) {
 				continue
 			}
 		}
 
+		// Soft deleted records are skipped before the offset is applied,
+		// so that they don't count towards it
+		if skip, err := deleted.skip(id); err != nil {
+			return nil, err
+		} else if skip {
+			continue
+		}
+
 		// Skip the first N entities according to the specified offset
 		if f.offsetCounter > 0 {
 			f.offsetCounter--
 			continue
 		}
 
-		item := it.Item()
-		ids = append(ids, extractID(item.Key()))
+		ids = append(ids, id)
 	}
 
 	return
@@ -1,6 +1,7 @@
 package tormenta
 
 import (
+	"context"
 	"reflect"
 	"sync"
 
@@ -34,7 +35,10 @@ func (db DB) get(txn *badger.Txn, entity Record, ctx map[string]interface{}, ids
 	}
 
 	entity.GetCreated()
-	entity.PostGet(ctx)
+
+	if err := postGet(entity, ctx); err != nil {
+		return false, err
+	}
 
 	return true, nil
 }
@@ -46,7 +50,13 @@ type getResult struct {
 	err    error
 }
 
-func (db DB) getIDsWithContext(txn *badger.Txn, target interface{}, ctx map[string]interface{}, ids ...gouuidv6.UUID) (int, error) {
+func (db DB) getIDsWithContext(goCtx context.Context, txn *badger.Txn, target interface{}, ctx map[string]interface{}, ids ...gouuidv6.UUID) (int, error) {
+	// Badger doesn't allow concurrent use of read-write transactions,
+	// so inside an explicit transaction, we fetch the records one by one
+	if db.txn != nil {
+		return db.getIDsSerially(goCtx, txn, target, ctx, ids...)
+	}
+
 	ch := make(chan getResult)
 	defer close(ch)
 	var wg sync.WaitGroup
@@ -62,6 +72,13 @@ func (db DB) getIDsWithContext(txn *badger.Txn, target interface{}, ctx map[stri
 		// Unlikely if the all JSON is saved with the schema, but I don't
 		// think we can risk it
 		go func(thisRecord Record, thisID gouuidv6.UUID) {
+			// If the query has been cancelled, there's no point
+			// fetching any more records
+			if err := ctxErr(goCtx); err != nil {
+				ch <- getResult{id: thisID, err: err}
+				return
+			}
+
 			found, err := db.get(txn, thisRecord, ctx, thisID)
 			ch <- getResult{
 				id:     thisID,
@@ -101,6 +118,26 @@ func (db DB) getIDsWithContext(txn *badger.Txn, target interface{}, ctx map[stri
 	return sortToOriginalIDsOrder(target, resultsList, ids), nil
 }
 
+func (db DB) getIDsSerially(goCtx context.Context, txn *badger.Txn, target interface{}, ctx map[string]interface{}, ids ...gouuidv6.UUID) (int, error) {
+	var resultsList []Record
+
+	for _, id := range ids {
+		if err := ctxErr(goCtx); err != nil {
+			return 0, err
+		}
+
+		record := newRecordFromSlice(target)
+		found, err := db.get(txn, record, ctx, id)
+		if err != nil {
+			return 0, err
+		} else if found {
+			resultsList = append(resultsList, record)
+		}
+	}
+
+	return sortToOriginalIDsOrder(target, resultsList, ids), nil
+}
+
 func sortToOriginalIDsOrder(target interface{}, resultList []Record, ids []gouuidv6.UUID) (counter int) {
 	resultMap := map[gouuidv6.UUID]Record{}
 	for _, record := range resultList {
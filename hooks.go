@@ -0,0 +1,101 @@
+package tormenta
+
+// Lifecycle hooks are optional - a record only needs to implement
+// the ones it actually uses.  Remember that the methods must be on the
+// same receiver type that is passed to Save/Get/Delete (usually a pointer)
+
+// PreSaver can be implemented by records that need to run business logic before being saved.
+// Returning an error aborts the save.  Any records returned are saved
+// in the same transaction
+type PreSaver interface {
+	PreSave(DB) ([]Record, error)
+}
+
+// PostSaver can be implemented by records that need to run business logic after being saved.
+// Returning an error aborts the save
+type PostSaver interface {
+	PostSave() error
+}
+
+// legacyPostSaver and legacyPostGetter are the hooks as they were
+// before they could return errors.  Records which still have them get them called,
+// rather than having them silently ignored
+type legacyPostSaver interface {
+	PostSave()
+}
+
+type legacyPostGetter interface {
+	PostGet(ctx map[string]interface{})
+}
+
+// PostGetter can be implemented by records that need to run business logic after being retrieved.
+// The context is the one passed through the get or query (see GetWithContext and SetContext).
+// Returning an error fails the get or query
+type PostGetter interface {
+	PostGet(ctx map[string]interface{}) error
+}
+
+// Validator can be implemented by records that need to check themselves before being saved.
+// It is called after PreSave, along with any validation tags.  Returning an error aborts the save.
+// Return a ValidationError or FieldError to report failures against particular fields
+type Validator interface {
+	Validate() error
+}
+
+// PreDeleter can be implemented by records that need to run business logic before being deleted.
+// Returning an error vetoes the delete.  Any records returned are deleted
+// in the same transaction (records which no longer exist are skipped)
+type PreDeleter interface {
+	PreDelete(DB) ([]Record, error)
+}
+
+// PostDeleter can be implemented by records that need to run business logic after being deleted.
+// It is called once the record, and any records returned by PreDelete, have been deleted,
+// but before the transaction commits.  Returning an error aborts the delete
+type PostDeleter interface {
+	PostDelete() error
+}
+
+func preSave(db DB, record Record) ([]Record, error) {
+	if preSaver, ok := record.(PreSaver); ok {
+		return preSaver.PreSave(db)
+	}
+
+	return nil, nil
+}
+
+func postSave(record Record) error {
+	if postSaver, ok := record.(PostSaver); ok {
+		return postSaver.PostSave()
+	} else if postSaver, ok := record.(legacyPostSaver); ok {
+		postSaver.PostSave()
+	}
+
+	return nil
+}
+
+func postGet(record Record, ctx map[string]interface{}) error {
+	if postGetter, ok := record.(PostGetter); ok {
+		return postGetter.PostGet(ctx)
+	} else if postGetter, ok := record.(legacyPostGetter); ok {
+		postGetter.PostGet(ctx)
+	}
+
+	return nil
+}
+
+func preDelete(db DB, record Record) ([]Record, error) {
+	if preDeleter, ok := record.(PreDeleter); ok {
+		return preDeleter.PreDelete(db)
+	}
+
+	return nil, nil
+}
+
+func postDelete(record Record) error {
+	if postDeleter, ok := record.(PostDeleter); ok {
+		return postDeleter.PostDelete()
+	}
+
+	return nil
+}
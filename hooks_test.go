@@ -0,0 +1,156 @@
+package tormenta_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/jpincas/tormenta"
+	"github.com/jpincas/tormenta/testtypes"
+)
+
+type hookErrorStruct struct {
+	tormenta.Model
+
+	FailValidate bool
+	FailPostSave bool
+	FailPostGet  bool
+	FailDelete   bool
+}
+
+func (s *hookErrorStruct) Validate() error {
+	if s.FailValidate {
+		return errors.New("validation failed")
+	}
+
+	return nil
+}
+
+func (s *hookErrorStruct) PostSave() error {
+	if s.FailPostSave {
+		return errors.New("post save failed")
+	}
+
+	return nil
+}
+
+func (s *hookErrorStruct) PostGet(ctx map[string]interface{}) error {
+	if s.FailPostGet {
+		return errors.New("post get failed")
+	}
+
+	return nil
+}
+
+func (s *hookErrorStruct) PostDelete() error {
+	if s.FailDelete {
+		return errors.New("post delete failed")
+	}
+
+	return nil
+}
+
+func Test_Hooks_Errors(t *testing.T) {
+	db, _ := tormenta.OpenTestWithOptions("data/tests", testDBOptions)
+	defer db.Close()
+
+	testCases := []struct {
+		testName string
+		entity   hookErrorStruct
+	}{
+		{"validate", hookErrorStruct{FailValidate: true}},
+		{"post save", hookErrorStruct{FailPostSave: true}},
+	}
+
+	for _, testCase := range testCases {
+		if n, err := db.Save(&testCase.entity); err == nil || n != 0 {
+			t.Errorf("Testing failing %s hook. Expected an error and nothing saved, got %v / %v", testCase.testName, err, n)
+		}
+
+		if testCase.entity.Version != 0 {
+			t.Errorf("Testing failing %s hook. Version should not have been bumped", testCase.testName)
+		}
+	}
+
+	if n, _ := db.Find(&[]hookErrorStruct{}).Count(); n != 0 {
+		t.Errorf("Testing failing save hooks. Expected nothing to have been saved, got %v", n)
+	}
+
+	// Failing post get
+	failGet := hookErrorStruct{FailPostGet: true}
+	db.Save(&failGet)
+
+	if _, err := db.Get(&hookErrorStruct{}, failGet.ID); err == nil {
+		t.Error("Testing failing post get hook. Expected an error from get but did not get one")
+	}
+
+	if _, err := db.Find(&[]hookErrorStruct{}).Run(); err == nil {
+		t.Error("Testing failing post get hook. Expected an error from query but did not get one")
+	}
+
+	// Failing post delete
+	failDelete := hookErrorStruct{FailDelete: true}
+	db.Save(&failDelete)
+
+	if err := db.Delete(&failDelete); err == nil {
+		t.Error("Testing failing post delete hook. Expected an error but did not get one")
+	}
+
+	if n, _ := db.Find(&[]hookErrorStruct{}).Count(); n != 2 {
+		t.Errorf("Testing failing post delete hook. Expected the record to still be there, got %v records", n)
+	}
+}
+
+func Test_Hooks_None(t *testing.T) {
+	db, _ := tormenta.OpenTestWithOptions("data/tests", testDBOptions)
+	defer db.Close()
+
+	// MiniStruct implements none of the hooks
+	miniStruct := testtypes.MiniStruct{}
+	if _, err := db.Save(&miniStruct); err != nil {
+		t.Errorf("Testing save of record without hooks. Got error %v", err)
+	}
+
+	if found, err := db.Get(&testtypes.MiniStruct{}, miniStruct.ID); err != nil || !found {
+		t.Errorf("Testing get of record without hooks. Expected found and no error, got %v / %v", found, err)
+	}
+
+	if err := db.Delete(&miniStruct); err != nil {
+		t.Errorf("Testing delete of record without hooks. Got error %v", err)
+	}
+}
+
+// legacyHookStruct has the hooks as they were before they could return errors
+type legacyHookStruct struct {
+	tormenta.Model
+
+	IsSaved   bool `tormenta:"-"`
+	Retrieved bool `tormenta:"-"`
+}
+
+func (s *legacyHookStruct) PostSave() {
+	s.IsSaved = true
+}
+
+func (s *legacyHookStruct) PostGet(ctx map[string]interface{}) {
+	s.Retrieved = true
+}
+
+func Test_Hooks_Legacy(t *testing.T) {
+	db, _ := tormenta.OpenTestWithOptions("data/tests", testDBOptions)
+	defer db.Close()
+
+	entity := legacyHookStruct{}
+	if _, err := db.Save(&entity); err != nil || !entity.IsSaved {
+		t.Errorf("Testing legacy post save hook. Expected it to run, got %v (error %v)", entity.IsSaved, err)
+	}
+
+	result := legacyHookStruct{}
+	if found, err := db.Get(&result, entity.ID); !found || err != nil || !result.Retrieved {
+		t.Errorf("Testing legacy post get hook. Expected it to run, got %v (error %v)", result.Retrieved, err)
+	}
+
+	var results []legacyHookStruct
+	if db.Find(&results).Run(); len(results) != 1 || !results[0].Retrieved {
+		t.Errorf("Testing legacy post get hook on a query. Expected it to run, got %v", results)
+	}
+}
@@ -0,0 +1,402 @@
+// Package httpapi serves the registered entity types of a DB as JSON over HTTP:
+//
+//	GET    /{type}?where=...&limit=...   find entities, with the query string syntax of Query.Parse
+//	GET    /{type}/count?where=...       count entities
+//	GET    /{type}/sum?index=Amount&...  sum an index, e.g. {"sum": 123.45, "count": 10}
+//	GET    /{type}/{id}                  get an entity
+//	POST   /{type}                       create an entity from the JSON body
+//	PUT    /{type}/{id}                  save an entity from the JSON body
+//	DELETE /{type}/{id}                  delete an entity
+//
+// {type} is the key root of the type, e.g. "order" for Order.
+// Only registered types are served (see DB.Register), so register them first, e.g.
+//
+//	db.Register(&Order{}, &Customer{})
+//	http.Handle("/api/", http.StripPrefix("/api", httpapi.New(db)))
+//
+// Saving goes through the usual triggers and validation.  As with Save, a PUT must give the version
+// of the entity it is replacing (as last returned by the API), so that concurrent changes aren't lost.
+// The other fields of the Model (the ID, times and soft deletion) are set by the DB, whatever the body says.
+// Errors are returned as {"error": "..."}, with a 400 for a bad query, ID or body, or a failed validation,
+// a 404 for an unknown type or entity, a 409 for a version conflict or a unique violation,
+// a 499 if the client goes away and a 504 if the request's deadline passes.
+// Errors from DB middleware, triggers or Validate methods can choose their own status by implementing
+// StatusError (e.g. with NewError) - anything else is a 500.
+// There is no authentication or access control - add it with your own HTTP middleware or DB middleware (see DB.Use)
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/jpincas/gouuidv6"
+	"github.com/jpincas/tormenta"
+)
+
+const (
+	pathCount = "count"
+	pathSum   = "sum"
+
+	querySumIndex = "index"
+
+	// The non-standard status for a request abandoned by the client, as used by nginx
+	statusClientClosedRequest = 499
+
+	ErrUnknownType      = "Unknown entity type %s"
+	ErrNotFound         = "No %s with ID %v"
+	ErrBadBody          = "The request body is not a valid %s: %v"
+	ErrNoSumIndex       = "A sum needs the index to sum, e.g. ?index=Amount"
+	ErrIndexNotSumable  = "Index %s is not a number"
+	ErrMethodNotAllowed = "Method %s is not allowed here"
+)
+
+// StatusError is an error which chooses the status code of the response.
+// Return one from DB middleware (see DB.Use), triggers or Validate methods,
+// e.g. a 403 when an access check fails
+type StatusError interface {
+	error
+	Status() int
+}
+
+type statusError struct {
+	status int
+	err    error
+}
+
+func (e statusError) Error() string { return e.err.Error() }
+func (e statusError) Status() int   { return e.status }
+
+// NewError wraps an error so that it gives the response the status code given
+func NewError(status int, err error) error {
+	return statusError{status: status, err: err}
+}
+
+// Server is an http.Handler serving the registered types of a DB
+type Server struct {
+	db *tormenta.DB
+}
+
+// New returns a server for the registered types of a DB.
+// Types registered later are served too
+func New(db *tormenta.DB) *Server {
+	return &Server{db: db}
+}
+
+// errorResponse is the body of every error response
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// sumResponse is the body of a sum response
+type sumResponse struct {
+	Sum   interface{} `json:"sum"`
+	Count int         `json:"count"`
+}
+
+// countResponse is the body of a count response
+type countResponse struct {
+	Count int `json:"count"`
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(parts) > 2 || parts[0] == "" {
+		http.NotFound(w, r)
+		return
+	}
+
+	entity, ok := s.entity(parts[0])
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Errorf(ErrUnknownType, parts[0]))
+		return
+	}
+
+	if len(parts) == 1 {
+		switch r.Method {
+		case http.MethodGet:
+			s.find(w, r, entity)
+		case http.MethodPost:
+			s.create(w, r, entity)
+		default:
+			writeError(w, http.StatusMethodNotAllowed, fmt.Errorf(ErrMethodNotAllowed, r.Method))
+		}
+
+		return
+	}
+
+	if r.Method == http.MethodGet {
+		switch parts[1] {
+		case pathCount:
+			s.count(w, r, entity)
+			return
+		case pathSum:
+			s.sum(w, r, entity)
+			return
+		}
+	}
+
+	id, err := parseID(parts[1])
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		s.get(w, r, entity, id)
+	case http.MethodPut:
+		s.put(w, r, entity, id)
+	case http.MethodDelete:
+		s.delete(w, r, entity, id)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf(ErrMethodNotAllowed, r.Method))
+	}
+}
+
+// entity returns a new, blank entity of the registered type with the given key root
+func (s *Server) entity(keyRoot string) (tormenta.Record, bool) {
+	for _, entity := range s.db.RegisteredTypes() {
+		if tormenta.KeyRootString(entity) == keyRoot {
+			return entity, true
+		}
+	}
+
+	return nil, false
+}
+
+// query builds a query from the request's query string.
+// Errors building it are the fault of the request
+func (s *Server) query(r *http.Request, entity tormenta.Record, ignoreLimitOffset bool) (*tormenta.Query, interface{}, error) {
+	results := reflect.New(reflect.SliceOf(reflect.TypeOf(entity).Elem())).Interface()
+	q := s.db.Find(results)
+
+	if err := q.Parse(ignoreLimitOffset, r.URL.RawQuery); err != nil {
+		return nil, nil, err
+	}
+
+	return q, results, q.Err()
+}
+
+func (s *Server) find(w http.ResponseWriter, r *http.Request, entity tormenta.Record) {
+	q, results, err := s.query(r, entity, false)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if _, err := q.RunCtx(r.Context()); err != nil {
+		writeError(w, statusOf(err), err)
+		return
+	}
+
+	// An empty list rather than null
+	if reflect.ValueOf(results).Elem().IsNil() {
+		results = []struct{}{}
+	}
+
+	writeJSON(w, http.StatusOK, results)
+}
+
+func (s *Server) count(w http.ResponseWriter, r *http.Request, entity tormenta.Record) {
+	q, _, err := s.query(r, entity, true)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	n, err := q.CountCtx(r.Context())
+	if err != nil {
+		writeError(w, statusOf(err), err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, countResponse{Count: n})
+}
+
+func (s *Server) sum(w http.ResponseWriter, r *http.Request, entity tormenta.Record) {
+	indexName := r.URL.Query().Get(querySumIndex)
+	if indexName == "" {
+		writeError(w, http.StatusBadRequest, errors.New(ErrNoSumIndex))
+		return
+	}
+
+	field, ok := reflect.TypeOf(entity).Elem().FieldByName(indexName)
+	if !ok {
+		writeError(w, http.StatusBadRequest, fmt.Errorf(tormenta.ErrFieldCouldNotBeFound, indexName))
+		return
+	}
+
+	if !tormenta.IsNumberKind(field.Type.Kind()) {
+		writeError(w, http.StatusBadRequest, fmt.Errorf(ErrIndexNotSumable, indexName))
+		return
+	}
+
+	q, _, err := s.query(r, entity, true)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	sum := reflect.New(field.Type)
+	n, err := q.SumCtx(r.Context(), sum.Interface(), indexName)
+	if err != nil {
+		writeError(w, statusOf(err), err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, sumResponse{Sum: sum.Elem().Interface(), Count: n})
+}
+
+func (s *Server) get(w http.ResponseWriter, r *http.Request, entity tormenta.Record, id gouuidv6.UUID) {
+	found, err := s.db.GetCtx(r.Context(), entity, id)
+	if err != nil {
+		writeError(w, statusOf(err), err)
+		return
+	} else if !found {
+		writeError(w, http.StatusNotFound, fmt.Errorf(ErrNotFound, tormenta.KeyRootString(entity), id))
+		return
+	}
+
+	writeJSON(w, http.StatusOK, entity)
+}
+
+func (s *Server) create(w http.ResponseWriter, r *http.Request, entity tormenta.Record) {
+	if err := decodeBody(r, entity); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	// Always a new entity, whatever the body says
+	resetModel(entity, false)
+
+	if _, err := s.db.SaveCtx(r.Context(), entity); err != nil {
+		writeError(w, statusOf(err), err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, entity)
+}
+
+func (s *Server) put(w http.ResponseWriter, r *http.Request, entity tormenta.Record, id gouuidv6.UUID) {
+	if err := decodeBody(r, entity); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	// The ID in the path takes priority, and only the version is taken from the body
+	resetModel(entity, true)
+	entity.SetID(id)
+
+	if _, err := s.db.SaveCtx(r.Context(), entity); err != nil {
+		writeError(w, statusOf(err), err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, entity)
+}
+
+func (s *Server) delete(w http.ResponseWriter, r *http.Request, entity tormenta.Record, id gouuidv6.UUID) {
+	found, err := s.deleteOnce(entity, id)
+
+	// If someone else got there first, trying again finds out whether the entity is still there
+	if tormenta.IsConflict(err) {
+		found, err = s.deleteOnce(entity, id)
+	}
+
+	if err != nil {
+		writeError(w, statusOf(err), err)
+		return
+	} else if !found {
+		writeError(w, http.StatusNotFound, fmt.Errorf(ErrNotFound, tormenta.KeyRootString(entity), id))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// deleteOnce gets and deletes an entity in a single transaction,
+// so that it can't disappear in between
+func (s *Server) deleteOnce(entity tormenta.Record, id gouuidv6.UUID) (found bool, err error) {
+	err = s.db.Update(func(tx *tormenta.Tx) (err error) {
+		if found, err = tx.Get(entity, id); err != nil || !found {
+			return err
+		}
+
+		return tx.Delete(entity)
+	})
+
+	return
+}
+
+// parseID reads an ID in the form it takes in JSON
+func parseID(s string) (id gouuidv6.UUID, err error) {
+	if err := json.Unmarshal([]byte(`"`+s+`"`), &id); err != nil {
+		return id, fmt.Errorf(tormenta.ErrBadIDFormat, s)
+	}
+
+	return id, nil
+}
+
+func decodeBody(r *http.Request, entity tormenta.Record) error {
+	if err := json.NewDecoder(r.Body).Decode(entity); err != nil {
+		return fmt.Errorf(ErrBadBody, tormenta.KeyRootString(entity), err)
+	}
+
+	return nil
+}
+
+// resetModel clears the fields of an entity's Model, which are the DB's to set rather than the client's,
+// e.g. so that a client can't create an entity that is already soft deleted.
+// The version can be kept, for the check that the entity hasn't changed in the meantime
+func resetModel(entity tormenta.Record, keepVersion bool) {
+	modelField := reflect.ValueOf(entity).Elem().FieldByName("Model")
+	if !modelField.IsValid() {
+		return
+	}
+
+	model := tormenta.Model{}
+	if keepVersion {
+		model.Version = modelField.Interface().(tormenta.Model).Version
+	}
+
+	modelField.Set(reflect.ValueOf(model))
+}
+
+// statusOf works out the status code for an error from the DB, which may have been wrapped
+func statusOf(err error) int {
+	var (
+		statusError StatusError
+		validation  tormenta.ValidationError
+		unique      tormenta.ErrUniqueViolation
+	)
+
+	switch {
+	case errors.As(err, &statusError):
+		return statusError.Status()
+	case errors.As(err, &validation):
+		return http.StatusBadRequest
+	case errors.As(err, &unique), tormenta.IsConflict(err):
+		return http.StatusConflict
+	case errors.Is(err, context.Canceled):
+		return statusClientClosedRequest
+	case errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout
+	}
+
+	return http.StatusInternalServerError
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, errorResponse{Error: err.Error()})
+}
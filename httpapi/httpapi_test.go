@@ -0,0 +1,229 @@
+package httpapi_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jpincas/gouuidv6"
+	"github.com/jpincas/tormenta"
+	"github.com/jpincas/tormenta/httpapi"
+)
+
+type Order struct {
+	tormenta.Model
+
+	Customer string `tormenta:"required"`
+	Code     string `tormenta:"unique"`
+	Amount   int
+}
+
+func setup(t *testing.T) (*tormenta.DB, *httptest.Server, []Order) {
+	db, err := tormenta.OpenTest("data/tests")
+	if err != nil {
+		t.Fatalf("Couldn't open test DB: %v", err)
+	}
+
+	db.Register(&Order{})
+
+	orders := []Order{
+		{Customer: "jon", Code: "a", Amount: 10},
+		{Customer: "jon", Code: "b", Amount: 20},
+		{Customer: "ann", Code: "c", Amount: 30},
+	}
+
+	for i := range orders {
+		db.Save(&orders[i])
+	}
+
+	return db, httptest.NewServer(httpapi.New(db)), orders
+}
+
+// idString gives an ID the way it appears in JSON
+func idString(id gouuidv6.UUID) string {
+	b, _ := json.Marshal(id)
+	return strings.Trim(string(b), `"`)
+}
+
+func do(t *testing.T, method, url, body string, result interface{}) int {
+	req, _ := http.NewRequest(method, url, strings.NewReader(body))
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Testing %s %s. Request failed: %v", method, url, err)
+	}
+	defer res.Body.Close()
+
+	if result != nil {
+		json.NewDecoder(res.Body).Decode(result)
+	}
+
+	return res.StatusCode
+}
+
+func Test_Read(t *testing.T) {
+	db, server, orders := setup(t)
+	defer db.Close()
+	defer server.Close()
+
+	var found []Order
+	if status := do(t, "GET", server.URL+"/order?where=index:Customer,match:jon&limit=1", "", &found); status != http.StatusOK || len(found) != 1 || found[0].Customer != "jon" {
+		t.Errorf("Testing find. Expected 1 of jon's orders, got status %v and %v", status, found)
+	}
+
+	var none []Order
+	if status := do(t, "GET", server.URL+"/order?where=index:Customer,match:nobody", "", &none); status != http.StatusOK || none == nil || len(none) != 0 {
+		t.Errorf("Testing find with no results. Expected an empty list, got status %v and %v", status, none)
+	}
+
+	var order Order
+	if status := do(t, "GET", server.URL+"/order/"+idString(orders[2].ID), "", &order); status != http.StatusOK || order.Customer != "ann" {
+		t.Errorf("Testing get. Expected ann's order, got status %v and %v", status, order)
+	}
+
+	var count struct{ Count int }
+	if status := do(t, "GET", server.URL+"/order/count?where=index:Customer,match:jon&limit=1", "", &count); status != http.StatusOK || count.Count != 2 {
+		t.Errorf("Testing count. Expected 2 (ignoring the limit), got status %v and %v", status, count.Count)
+	}
+
+	var sum struct{ Sum, Count int }
+	if status := do(t, "GET", server.URL+"/order/sum?index=Amount&where=index:Customer,match:jon", "", &sum); status != http.StatusOK || sum.Sum != 30 || sum.Count != 2 {
+		t.Errorf("Testing sum. Expected 30 over 2 orders, got status %v and %v", status, sum)
+	}
+}
+
+func Test_Write(t *testing.T) {
+	db, server, orders := setup(t)
+	defer db.Close()
+	defer server.Close()
+
+	var created Order
+	if status := do(t, "POST", server.URL+"/order", `{"Customer": "bob", "Code": "d", "Amount": 40}`, &created); status != http.StatusCreated || created.ID.IsNil() {
+		t.Errorf("Testing create. Expected a new order, got status %v and %v", status, created)
+	}
+
+	if n, _ := db.Find(&[]Order{}).Match("Customer", "bob").Count(); n != 1 {
+		t.Errorf("Testing create. Expected the new order to be saved, got %v", n)
+	}
+
+	// The Model is the DB's to set, apart from the version of a PUT
+	var clientModel Order
+	body := `{"Customer": "bob", "Code": "e", "version": 7, "created": "2000-01-01T00:00:00Z", "deletedAt": "2000-01-01T00:00:00Z"}`
+	if status := do(t, "POST", server.URL+"/order", body, &clientModel); status != http.StatusCreated ||
+		clientModel.Version != 1 || !clientModel.DeletedAt.IsZero() || clientModel.Created.Year() == 2000 {
+		t.Errorf("Testing create with Model fields set. Expected them to be ignored, got status %v and %v", status, clientModel.Model)
+	}
+
+	if status := do(t, "GET", server.URL+"/order/"+idString(clientModel.ID), "", nil); status != http.StatusOK {
+		t.Errorf("Testing create with deletedAt set. Expected the order not to be soft deleted, got status %v", status)
+	}
+
+	body = `{"Customer": "ann", "Code": "c", "Amount": 35, "version": 1, "deletedAt": "2000-01-01T00:00:00Z"}`
+	if status := do(t, "PUT", server.URL+"/order/"+idString(orders[2].ID), body, nil); status != http.StatusOK {
+		t.Errorf("Testing update with deletedAt set. Expected %v, got %v", http.StatusOK, status)
+	}
+
+	if status := do(t, "GET", server.URL+"/order/"+idString(orders[2].ID), "", nil); status != http.StatusOK {
+		t.Errorf("Testing update with deletedAt set. Expected the order not to be soft deleted, got status %v", status)
+	}
+
+	// A PUT needs the current version
+	var updated Order
+	body = `{"Customer": "jon", "Code": "a", "Amount": 15, "version": 1}`
+	if status := do(t, "PUT", server.URL+"/order/"+idString(orders[0].ID), body, &updated); status != http.StatusOK || updated.Amount != 15 {
+		t.Errorf("Testing update. Expected the order to be updated, got status %v and %v", status, updated)
+	}
+
+	if status := do(t, "PUT", server.URL+"/order/"+idString(orders[0].ID), body, nil); status != http.StatusConflict {
+		t.Errorf("Testing update with a stale version. Expected %v, got %v", http.StatusConflict, status)
+	}
+
+	if status := do(t, "DELETE", server.URL+"/order/"+idString(orders[1].ID), "", nil); status != http.StatusNoContent {
+		t.Errorf("Testing delete. Expected %v, got %v", http.StatusNoContent, status)
+	}
+
+	if status := do(t, "GET", server.URL+"/order/"+idString(orders[1].ID), "", nil); status != http.StatusNotFound {
+		t.Errorf("Testing get after delete. Expected %v, got %v", http.StatusNotFound, status)
+	}
+}
+
+func Test_Errors(t *testing.T) {
+	db, server, _ := setup(t)
+	defer db.Close()
+	defer server.Close()
+
+	testCases := []struct {
+		testName       string
+		method, path   string
+		body           string
+		expectedStatus int
+	}{
+		{"bad limit", "GET", "/order?limit=ten", "", http.StatusBadRequest},
+		{"bad where", "GET", "/order?where=nonsense", "", http.StatusBadRequest},
+		{"unknown index", "GET", "/order?where=index:Nonsense,match:1", "", http.StatusBadRequest},
+		{"unknown order", "GET", "/order?order=Nonsense", "", http.StatusBadRequest},
+		{"unknown order in count", "GET", "/order/count?order=Nonsense", "", http.StatusBadRequest},
+		{"bad index value", "GET", "/order?where=index:Amount,match:abc", "", http.StatusBadRequest},
+		{"bad range value", "GET", "/order/count?where=index:Amount,start:abc", "", http.StatusBadRequest},
+		{"bad id", "GET", "/order/nonsense", "", http.StatusBadRequest},
+		{"sum without index", "GET", "/order/sum", "", http.StatusBadRequest},
+		{"sum of a string", "GET", "/order/sum?index=Customer", "", http.StatusBadRequest},
+		{"unknown type", "GET", "/customer", "", http.StatusNotFound},
+		{"unknown id", "DELETE", "/order/" + idString(gouuidv6.New()), "", http.StatusNotFound},
+		{"bad body", "POST", "/order", "{", http.StatusBadRequest},
+		{"invalid", "POST", "/order", `{"Code": "e"}`, http.StatusBadRequest},
+		{"unique violation", "POST", "/order", `{"Customer": "bob", "Code": "a"}`, http.StatusConflict},
+		{"method", "PATCH", "/order", "", http.StatusMethodNotAllowed},
+	}
+
+	for _, testCase := range testCases {
+		var result struct{ Error string }
+		status := do(t, testCase.method, server.URL+testCase.path, testCase.body, &result)
+		if status != testCase.expectedStatus {
+			t.Errorf("Testing %s. Expected status %v, got %v", testCase.testName, testCase.expectedStatus, status)
+		}
+
+		if result.Error == "" {
+			t.Errorf("Testing %s. Expected an error message", testCase.testName)
+		}
+	}
+}
+
+func Test_ErrorStatus(t *testing.T) {
+	db, server, orders := setup(t)
+	defer db.Close()
+	defer server.Close()
+
+	// Middleware can choose the status of its errors
+	db.Use(func(next tormenta.Handler) tormenta.Handler {
+		return func(op tormenta.Operation) error {
+			switch op.Type {
+			case tormenta.DeleteOperation:
+				return httpapi.NewError(http.StatusForbidden, errors.New("no deleting"))
+			case tormenta.QueryOperation:
+				return fmt.Errorf("finding orders: %w", context.DeadlineExceeded)
+			}
+
+			return next(op)
+		}
+	})
+
+	testCases := []struct {
+		testName       string
+		method, path   string
+		expectedStatus int
+	}{
+		{"middleware status", "DELETE", "/order/" + idString(orders[0].ID), http.StatusForbidden},
+		{"deadline", "GET", "/order", http.StatusGatewayTimeout},
+	}
+
+	for _, testCase := range testCases {
+		if status := do(t, testCase.method, server.URL+testCase.path, "", nil); status != testCase.expectedStatus {
+			t.Errorf("Testing %s. Expected status %v, got %v", testCase.testName, testCase.expectedStatus, status)
+		}
+	}
+}
@@ -17,7 +17,14 @@ import (
 // i:indexname:root:indexcontent:entityID
 // i:fullStruct:customer:5:324ds-3werwf-234wef-23wef
 
-func index(txn *badger.Txn, entity Record) error {
+// kvWriter is satisfied by both Badger transactions and write batches,
+// so that the same code can write keys in either
+type kvWriter interface {
+	SetEntry(e *badger.Entry) error
+	Delete(key []byte) error
+}
+
+func indexKeys(entity Record) [][]byte {
 	keys := indexStruct(
 		recordValue(entity),
 		entity,
@@ -26,8 +33,20 @@ func index(txn *badger.Txn, entity Record) error {
 		nil,
 	)
 
+	return append(keys, compositeIndexKeys(entity)...)
+}
+
+func index(w kvWriter, entity Record, expiresAt uint64) error {
+	keys := indexKeys(entity)
+
 	for i := range keys {
-		if err := txn.Set(keys[i], []byte{}); err != nil {
+		if err := setEntry(w, keys[i], []byte{}, expiresAt); err != nil {
+			return err
+		}
+	}
+
+	for _, k := range uniqueKeys(entity) {
+		if err := setEntry(w, k.key, entity.GetID().Bytes(), expiresAt); err != nil {
 			return err
 		}
 	}
@@ -35,17 +54,75 @@ func index(txn *badger.Txn, entity Record) error {
 	return nil
 }
 
-func deIndex(txn *badger.Txn, entity Record) error {
-	keys := indexStruct(
-		recordValue(entity),
-		entity,
-		KeyRoot(entity),
-		entity.GetID(),
-		nil,
-	)
+// reIndex brings the index keys of an entity up to date, given the previously
+// stored version (nil for a new entity).  Only the keys that have actually changed
+// are written or deleted, unless expiry is involved, in which case all the keys
+// are rewritten so that they keep the same expiry time as the content
+func reIndex(w kvWriter, entity, previous Record, expiresAt uint64) error {
+	if previous == nil {
+		return index(w, entity, expiresAt)
+	}
+
+	previousTTL, err := recordTTL(previous)
+	if err != nil {
+		return err
+	}
+
+	if expiresAt > 0 || previousTTL > 0 {
+		if err := deIndex(w, previous); err != nil {
+			return err
+		}
+
+		return index(w, entity, expiresAt)
+	}
+
+	if err := replaceKeys(w, indexKeys(previous), indexKeys(entity), []byte{}); err != nil {
+		return err
+	}
+
+	return replaceKeys(w, uniqueKeyBytes(previous), uniqueKeyBytes(entity), entity.GetID().Bytes())
+}
+
+// replaceKeys writes the new keys which aren't among the old ones,
+// and deletes the old keys which aren't among the new ones
+func replaceKeys(w kvWriter, previous, current [][]byte, value []byte) error {
+	oldKeys := map[string]bool{}
+	for _, key := range previous {
+		oldKeys[string(key)] = true
+	}
+
+	newKeys := map[string]bool{}
+	for _, key := range current {
+		newKeys[string(key)] = true
+		if !oldKeys[string(key)] {
+			if err := setEntry(w, key, value, 0); err != nil {
+				return err
+			}
+		}
+	}
+
+	for key := range oldKeys {
+		if !newKeys[key] {
+			if err := w.Delete([]byte(key)); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func deIndex(w kvWriter, entity Record) error {
+	keys := indexKeys(entity)
 
 	for i := range keys {
-		if err := txn.Delete(keys[i]); err != nil {
+		if err := w.Delete(keys[i]); err != nil {
+			return err
+		}
+	}
+
+	for _, key := range uniqueKeyBytes(entity) {
+		if err := w.Delete(key); err != nil {
 			return err
 		}
 	}
@@ -62,13 +139,15 @@ func indexStruct(v reflect.Value, entity Record, keyRoot []byte, id gouuidv6.UUI
 			indexName = nestedIndexKeyRoot(path, indexName)
 		}
 
+		mode := fieldIndexMode(fieldType)
+
 		if !isTaggedWith(fieldType, tormentaTagNoIndex, tormentaTagNoSave) {
 
 			switch fieldType.Type.Kind() {
 
 			// Slice: index members individually
 			case reflect.Slice:
-				keys = append(keys, getMultipleIndexKeys(v.Field(i), keyRoot, id, indexName)...)
+				keys = append(keys, getMultipleIndexKeys(v.Field(i), keyRoot, id, indexName, mode)...)
 
 			// Array: index members individually
 			case reflect.Array:
@@ -76,15 +155,15 @@ func indexStruct(v reflect.Value, entity Record, keyRoot []byte, id gouuidv6.UUI
 				if fieldType.Type == reflect.TypeOf(gouuidv6.UUID{}) {
 					keys = append(keys, makeIndexKey(keyRoot, id, indexName, v.Field(i).Interface()))
 				} else {
-					keys = append(keys, getMultipleIndexKeys(v.Field(i), keyRoot, id, indexName)...)
+					keys = append(keys, getMultipleIndexKeys(v.Field(i), keyRoot, id, indexName, mode)...)
 				}
 
 			// Strings: either straight index, or split by words
 			case reflect.String:
 				if isTaggedWith(fieldType, tormentaTagSplit) {
-					keys = append(keys, getSplitStringIndexes(v.Field(i), keyRoot, id, indexName)...)
+					keys = append(keys, getSplitStringIndexes(v.Field(i), keyRoot, id, indexName, mode)...)
 				} else {
-					keys = append(keys, makeIndexKey(keyRoot, id, indexName, v.Field(i).Interface()))
+					keys = append(keys, makeIndexKeyWithMode(keyRoot, id, indexName, v.Field(i).Interface(), mode))
 				}
 
 			// Anonymous/ Nested Structs
@@ -123,35 +202,39 @@ func MakeIndexKey(root []byte, id gouuidv6.UUID, indexName []byte, indexContent
 }
 
 func makeIndexKey(root []byte, id gouuidv6.UUID, indexName []byte, indexContent interface{}) []byte {
+	return makeIndexKeyWithMode(root, id, indexName, indexContent, indexModeLower)
+}
+
+func makeIndexKeyWithMode(root []byte, id gouuidv6.UUID, indexName []byte, indexContent interface{}, mode indexMode) []byte {
 	return bytes.Join(
 		[][]byte{
 			[]byte(indexKeyPrefix),
 			root,
 			indexName,
-			interfaceToBytes(indexContent),
+			encodeIndexValue(indexContent, mode),
 			id.Bytes(),
 		},
 		[]byte(keySeparator),
 	)
 }
 
-func getMultipleIndexKeys(v reflect.Value, root []byte, id gouuidv6.UUID, indexName []byte) (keys [][]byte) {
+func getMultipleIndexKeys(v reflect.Value, root []byte, id gouuidv6.UUID, indexName []byte, mode indexMode) (keys [][]byte) {
 	for i := 0; i < v.Len(); i++ {
-		key := makeIndexKey(root, id, indexName, v.Index(i).Interface())
+		key := makeIndexKeyWithMode(root, id, indexName, v.Index(i).Interface(), mode)
 		keys = append(keys, key)
 	}
 
 	return
 }
 
-func getSplitStringIndexes(v reflect.Value, root []byte, id gouuidv6.UUID, indexName []byte) (keys [][]byte) {
+func getSplitStringIndexes(v reflect.Value, root []byte, id gouuidv6.UUID, indexName []byte, mode indexMode) (keys [][]byte) {
 	strings := strings.Split(v.String(), " ")
 
 	// Clean non-content words
 	strings = removeNonContentWords(strings)
 
 	for _, s := range strings {
-		key := makeIndexKey(root, id, indexName, s)
+		key := makeIndexKeyWithMode(root, id, indexName, s, mode)
 		keys = append(keys, key)
 	}
 
@@ -0,0 +1,104 @@
+package tormenta
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+// indexMode says how the strings of a field are normalised for indexing.
+// By default they are lower cased, which makes searches case insensitive.
+// `tormenta:"index=exact"` keeps them as they are, for case sensitive searches
+// on things like product codes and tokens, and `tormenta:"index=fold"` applies
+// Unicode case folding and strips accents, so that e.g. "Crème" matches "CREME"
+type indexMode int
+
+const (
+	indexModeLower indexMode = iota
+	indexModeExact
+	indexModeFold
+)
+
+const (
+	tormentaTagIndexExact = "exact"
+	tormentaTagIndexFold  = "fold"
+)
+
+func fieldIndexMode(field reflect.StructField) indexMode {
+	switch value, _ := getTormentaTagValue(field, tormentaTagIndex); value {
+	case tormentaTagIndexExact:
+		return indexModeExact
+	case tormentaTagIndexFold:
+		return indexModeFold
+	}
+
+	return indexModeLower
+}
+
+// targetIndexMode gets the index mode of a field of the entity a query is searching for
+func targetIndexMode(target interface{}, fieldName string) indexMode {
+	// Raw queries may not know the entity
+	if target == nil {
+		return indexModeLower
+	}
+
+	t := reflect.Indirect(reflect.ValueOf(target)).Type()
+	if t.Kind() == reflect.Slice {
+		t = t.Elem()
+	}
+
+	if field, ok := t.FieldByName(fieldName); ok {
+		return fieldIndexMode(field)
+	}
+
+	return indexModeLower
+}
+
+func normaliseString(s string, mode indexMode) string {
+	switch mode {
+	case indexModeExact:
+		return s
+	case indexModeFold:
+		// Decompose, so that accents become separate marks which can be removed.
+		// Transformers aren't safe for concurrent use, so we need a new one each time
+		stripAccents := transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC)
+		if stripped, _, err := transform.String(stripAccents, s); err == nil {
+			s = stripped
+		}
+
+		return cases.Fold().String(s)
+	}
+
+	return strings.ToLower(s)
+}
+
+// encodeIndexValue encodes a field value for an index key,
+// normalising strings according to the index mode of the field
+func encodeIndexValue(value interface{}, mode indexMode) []byte {
+	if mode != indexModeLower && value != nil {
+		if v := reflect.ValueOf(value); v.Kind() == reflect.String {
+			return []byte(normaliseString(v.String(), mode))
+		}
+	}
+
+	return interfaceToBytes(value)
+}
+
+// encodeQueryValue encodes a value provided by the user to search an index,
+// normalising strings in the same way as the field was indexed
+func encodeQueryValue(value interface{}, kind reflect.Kind, mode indexMode) ([]byte, error) {
+	if mode != indexModeLower && value != nil {
+		switch kind {
+		case reflect.String, reflect.Slice, reflect.Array:
+			return []byte(normaliseString(fmt.Sprint(value), mode)), nil
+		}
+	}
+
+	return interfaceToBytesWithOverride(value, kind)
+}
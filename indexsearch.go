@@ -1,6 +1,7 @@
 package tormenta
 
 import (
+	"context"
 	"reflect"
 
 	"github.com/dgraph-io/badger"
@@ -34,6 +35,9 @@ type indexSearch struct {
 
 	sumIndexName []byte
 	sumTarget    interface{}
+
+	// Cancellation context
+	goCtx context.Context
 }
 
 func (i indexSearch) isLimitMet(noIDsSoFar int) bool {
@@ -59,7 +63,7 @@ func (i indexSearch) getIteratorOptions() badger.IteratorOptions {
 	return options
 }
 
-func (i indexSearch) execute(txn *badger.Txn) (ids idList) {
+func (i indexSearch) execute(txn *badger.Txn) (ids idList, err error) {
 	// Set ranges and init the offset counter
 	i.setRanges()
 	i.offsetCounter = i.offset
@@ -74,6 +78,10 @@ func (i indexSearch) execute(txn *badger.Txn) (ids idList) {
 	defer it.Close()
 
 	for it.Seek(i.seekFrom); it.ValidForPrefix(i.validTo) && !i.isLimitMet(len(ids)); it.Next() {
+		if err = ctxErr(i.goCtx); err != nil {
+			return nil, err
+		}
+
 		item := it.Item()
 		thisID := extractID(item.Key())
 
@@ -0,0 +1,70 @@
+package tormenta
+
+import (
+	"bytes"
+
+	"github.com/dgraph-io/badger"
+	"github.com/jpincas/gouuidv6"
+)
+
+// KeyCount is the number of keys stored under a name, e.g. an entity type or an index
+type KeyCount struct {
+	Name string
+	Keys int
+}
+
+// StoredTypes lists the key roots of all the entity types with records in the DB,
+// whether or not they are registered, along with the number of records of each.
+// It reads keys only, in chunks, so it is quick, but will take a while on a very large DB
+func (db DB) StoredTypes() ([]KeyCount, error) {
+	return db.countKeysByPart([]byte(contentKeyPrefix+keySeparator), 1)
+}
+
+// StoredIndexes lists the names of the indexes (including composite indexes) stored for an entity type,
+// given by its key root, along with the number of keys in each
+func (db DB) StoredIndexes(keyRoot string) ([]KeyCount, error) {
+	return db.countKeysByPart(typePrefix(indexKeyPrefix, []byte(keyRoot)), 2)
+}
+
+// countKeysByPart counts the keys under a prefix, grouped by one of the parts of the key,
+// in the order they are stored
+func (db DB) countKeysByPart(prefix []byte, part int) (counts []KeyCount, err error) {
+	err = db.scanPrefix(prefix, false, func(chunk []rawItem) error {
+		for _, item := range chunk {
+			name := string(bytes.Split(item.key, []byte(keySeparator))[part])
+			if len(counts) > 0 && counts[len(counts)-1].Name == name {
+				counts[len(counts)-1].Keys++
+			} else {
+				counts = append(counts, KeyCount{Name: name, Keys: 1})
+			}
+		}
+
+		return nil
+	})
+
+	return
+}
+
+// GetRaw gets a record of an entity type, given by its key root, as it is stored,
+// without unserialising it (or the codec identifier, if it was stored with a codec).
+// No hooks are run, and soft deleted records are included
+func (db DB) GetRaw(keyRoot string, id gouuidv6.UUID) (value []byte, found bool, err error) {
+	err = db.view(func(txn *badger.Txn) error {
+		item, err := txn.Get(newContentKey([]byte(keyRoot), id).bytes())
+		if err == badger.ErrKeyNotFound {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		found = true
+		if value, err = item.ValueCopy(nil); err != nil {
+			return err
+		}
+
+		_, value, err = splitValue(value)
+		return err
+	})
+
+	return
+}
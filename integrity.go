@@ -0,0 +1,250 @@
+package tormenta
+
+import (
+	"github.com/dgraph-io/badger"
+	"github.com/jpincas/gouuidv6"
+)
+
+// IntegrityReport lists the problems found with the index keys
+// (including unique keys) of an entity type
+type IntegrityReport struct {
+	KeyRoot string
+
+	// Records is the number of records checked
+	Records int
+
+	// Orphaned keys point to records that don't exist,
+	// e.g. after an interrupted save or delete
+	Orphaned [][]byte
+
+	// Missing keys should exist for the records as they are stored, but don't
+	Missing [][]byte
+
+	// Stale keys point to records that exist, but don't match them,
+	// e.g. after a field has been tagged noindex
+	Stale [][]byte
+
+	// Repaired is set once the problems have been fixed
+	Repaired bool
+}
+
+// OK says whether no problems were found
+func (r IntegrityReport) OK() bool {
+	return len(r.Orphaned) == 0 && len(r.Missing) == 0 && len(r.Stale) == 0
+}
+
+// CheckIntegrity cross-checks the index keys of the given entity types
+// (or of every registered type, if none are given) against the stored records,
+// and reports on any that are orphaned, missing or stale.
+// Nothing is changed - see RepairIntegrity.
+// Records being written during the check may show up as problems, so run it when things are quiet
+func (db DB) CheckIntegrity(entities ...Record) ([]IntegrityReport, error) {
+	return db.checkIntegrity(false, entities)
+}
+
+// RepairIntegrity checks the index keys like CheckIntegrity,
+// then deletes the orphaned and stale keys and writes the missing ones.
+// It returns the reports of the problems found, marked as repaired
+func (db DB) RepairIntegrity(entities ...Record) ([]IntegrityReport, error) {
+	return db.checkIntegrity(true, entities)
+}
+
+func (db DB) checkIntegrity(repair bool, entities []Record) ([]IntegrityReport, error) {
+	if len(entities) == 0 {
+		entities = db.RegisteredTypes()
+	}
+
+	var reports []IntegrityReport
+	for _, entity := range entities {
+		report, missing, err := db.checkTypeIntegrity(entity)
+		if err != nil {
+			return reports, err
+		}
+
+		if repair && !report.OK() {
+			if err := db.repairIntegrity(report, missing); err != nil {
+				return reports, err
+			}
+
+			report.Repaired = true
+		}
+
+		reports = append(reports, report)
+	}
+
+	return reports, nil
+}
+
+// checkTypeIntegrity checks a single entity type.
+// As well as the report, it returns the missing keys complete with the values
+// and expiry they should have, ready for repair
+func (db DB) checkTypeIntegrity(entity Record) (report IntegrityReport, missing []rawItem, err error) {
+	root := KeyRoot(entity)
+	report.KeyRoot = string(root)
+
+	// Every record should have all its keys
+	err = db.scanPrefix(typePrefix(contentKeyPrefix, root), true, func(chunk []rawItem) error {
+		report.Records += len(chunk)
+
+		return db.KV.View(func(txn *badger.Txn) error {
+			for _, item := range chunk {
+				record, err := db.decodeContent(entity, item)
+				if err != nil {
+					return err
+				}
+
+				for _, key := range expectedKeys(record) {
+					if _, err := txn.Get(key.key); err == badger.ErrKeyNotFound {
+						report.Missing = append(report.Missing, key.key)
+						missing = append(missing, rawItem{key: key.key, value: key.value, expiresAt: item.expiresAt})
+					} else if err != nil {
+						return err
+					}
+				}
+			}
+
+			return nil
+		})
+	})
+
+	if err != nil {
+		return
+	}
+
+	// Every key should belong to a record, and match it.
+	// Index keys end with the ID of the record,
+	// whereas unique keys hold it as their value
+	for _, keyPrefix := range []string{indexKeyPrefix, uniqueKeyPrefix} {
+		isUnique := keyPrefix == uniqueKeyPrefix
+
+		err = db.scanPrefix(typePrefix(keyPrefix, root), isUnique, func(chunk []rawItem) error {
+			// Records are decoded once per chunk, however many of their keys it contains
+			keysByID := map[gouuidv6.UUID]map[string]bool{}
+
+			return db.KV.View(func(txn *badger.Txn) error {
+				for _, item := range chunk {
+					id := extractID(item.key)
+					if isUnique {
+						copy(id[:], item.value)
+					}
+
+					keys, ok := keysByID[id]
+					if !ok {
+						var err error
+						if keys, err = db.storedRecordKeys(txn, entity, id); err != nil {
+							return err
+						}
+
+						keysByID[id] = keys
+					}
+
+					if keys == nil {
+						report.Orphaned = append(report.Orphaned, item.key)
+					} else if !keys[string(item.key)] {
+						report.Stale = append(report.Stale, item.key)
+					}
+				}
+
+				return nil
+			})
+		})
+
+		if err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+// expectedKey is a key that a record should have, along with its value
+type expectedKey struct {
+	key, value []byte
+}
+
+func expectedKeys(record Record) (keys []expectedKey) {
+	for _, key := range indexKeys(record) {
+		keys = append(keys, expectedKey{key: key, value: []byte{}})
+	}
+
+	for _, key := range uniqueKeyBytes(record) {
+		keys = append(keys, expectedKey{key: key, value: record.GetID().Bytes()})
+	}
+
+	return
+}
+
+// storedRecordKeys works out the set of keys a stored record should have.
+// The set is nil if there is no such record
+func (db DB) storedRecordKeys(txn *badger.Txn, entity Record, id gouuidv6.UUID) (map[string]bool, error) {
+	contentKey := newContentKey(KeyRoot(entity), id).bytes()
+
+	item, err := txn.Get(contentKey)
+	if err == badger.ErrKeyNotFound {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	value, err := item.ValueCopy(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	record, err := db.decodeContent(entity, rawItem{key: contentKey, value: value})
+	if err != nil {
+		return nil, err
+	}
+
+	keys := map[string]bool{}
+	for _, key := range expectedKeys(record) {
+		keys[string(key.key)] = true
+	}
+
+	return keys, nil
+}
+
+// repairIntegrity fixes the problems in a report, in chunks
+func (db DB) repairIntegrity(report IntegrityReport, missing []rawItem) error {
+	toDelete := append(append([][]byte{}, report.Orphaned...), report.Stale...)
+
+	for start := 0; start < len(toDelete); start += maintenanceChunkSize {
+		end := start + maintenanceChunkSize
+		if end > len(toDelete) {
+			end = len(toDelete)
+		}
+
+		if err := db.KV.Update(func(txn *badger.Txn) error {
+			for _, key := range toDelete[start:end] {
+				if err := txn.Delete(key); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		}); err != nil {
+			return err
+		}
+	}
+
+	for start := 0; start < len(missing); start += maintenanceChunkSize {
+		end := start + maintenanceChunkSize
+		if end > len(missing) {
+			end = len(missing)
+		}
+
+		if err := db.KV.Update(func(txn *badger.Txn) error {
+			for _, item := range missing[start:end] {
+				if err := setEntry(txn, item.key, item.value, item.expiresAt); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
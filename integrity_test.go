@@ -0,0 +1,115 @@
+package tormenta_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/dgraph-io/badger"
+	"github.com/jpincas/gouuidv6"
+	"github.com/jpincas/tormenta"
+	"github.com/jpincas/tormenta/testtypes"
+)
+
+func Test_Integrity(t *testing.T) {
+	db, _ := tormenta.OpenTestWithOptions("data/tests", testDBOptions)
+	defer db.Close()
+
+	entity := testtypes.MiniStruct{IntField: 1, StringField: "jon"}
+	db.Save(&entity, &testtypes.MiniStruct{IntField: 2}, &testtypes.MiniStruct{IntField: 3})
+
+	// A healthy DB has no problems
+	reports, err := db.CheckIntegrity(&testtypes.MiniStruct{})
+	if err != nil {
+		t.Fatalf("Testing integrity check. Got error %v", err)
+	}
+
+	if len(reports) != 1 || !reports[0].OK() || reports[0].Records != 3 {
+		t.Fatalf("Testing integrity check. Expected a clean report on 3 records, got %+v", reports)
+	}
+
+	root := []byte("ministruct")
+	orphanedKey := tormenta.MakeIndexKey(root, gouuidv6.New(), []byte("IntField"), 1)
+	staleKey := tormenta.MakeIndexKey(root, entity.ID, []byte("IntField"), 99)
+	missingKey := tormenta.MakeIndexKey(root, entity.ID, []byte("StringField"), "jon")
+
+	db.KV.Update(func(txn *badger.Txn) error {
+		txn.Set(orphanedKey, []byte{})
+		txn.Set(staleKey, []byte{})
+		return txn.Delete(missingKey)
+	})
+
+	reports, err = db.CheckIntegrity(&testtypes.MiniStruct{})
+	if err != nil {
+		t.Fatalf("Testing integrity check. Got error %v", err)
+	}
+
+	report := reports[0]
+	if len(report.Orphaned) != 1 || string(report.Orphaned[0]) != string(orphanedKey) {
+		t.Errorf("Testing integrity check. Expected the orphaned key to be reported, got %v", report.Orphaned)
+	}
+
+	if len(report.Stale) != 1 || string(report.Stale[0]) != string(staleKey) {
+		t.Errorf("Testing integrity check. Expected the stale key to be reported, got %v", report.Stale)
+	}
+
+	if len(report.Missing) != 1 || string(report.Missing[0]) != string(missingKey) {
+		t.Errorf("Testing integrity check. Expected the missing key to be reported, got %v", report.Missing)
+	}
+
+	if report.Repaired {
+		t.Error("Testing integrity check. Checking shouldn't repair anything")
+	}
+
+	// Repair, then check again
+	reports, err = db.RepairIntegrity(&testtypes.MiniStruct{})
+	if err != nil {
+		t.Fatalf("Testing integrity repair. Got error %v", err)
+	}
+
+	if !reports[0].Repaired || reports[0].OK() {
+		t.Errorf("Testing integrity repair. Expected the problems to be reported as repaired, got %+v", reports[0])
+	}
+
+	reports, _ = db.CheckIntegrity(&testtypes.MiniStruct{})
+	if !reports[0].OK() {
+		t.Errorf("Testing integrity repair. Expected a clean report after repair, got %+v", reports[0])
+	}
+
+	if n, _ := db.Find(&[]testtypes.MiniStruct{}).Match("StringField", "jon").Count(); n != 1 {
+		t.Errorf("Testing integrity repair. Expected the missing key to be restored, got %v results", n)
+	}
+}
+
+func Test_Integrity_UniqueKeys(t *testing.T) {
+	db, _ := tormenta.OpenTestWithOptions("data/tests", testDBOptions)
+	defer db.Close()
+
+	entity := testtypes.UniqueStruct{Email: "jon@example.com"}
+	db.Save(&entity)
+
+	// A unique key left behind by a record that no longer exists would block the value
+	orphanedKey := []byte(strings.Join([]string{"u", "uniquestruct", "Email", "jane@example.com"}, "~±^"))
+	db.KV.Update(func(txn *badger.Txn) error {
+		id := gouuidv6.New()
+		return txn.Set(orphanedKey, id.Bytes())
+	})
+
+	if _, err := db.Save(&testtypes.UniqueStruct{Email: "jane@example.com"}); err == nil {
+		t.Fatal("Testing integrity of unique keys. Expected the orphaned key to block the value")
+	}
+
+	db.Register(&testtypes.UniqueStruct{})
+
+	reports, err := db.RepairIntegrity()
+	if err != nil {
+		t.Fatalf("Testing integrity of unique keys. Got error %v", err)
+	}
+
+	if len(reports) != 1 || len(reports[0].Orphaned) != 1 || string(reports[0].Orphaned[0]) != string(orphanedKey) {
+		t.Fatalf("Testing integrity of unique keys. Expected orphaned keys, got %+v", reports)
+	}
+
+	if _, err := db.Save(&testtypes.UniqueStruct{Email: "jane@example.com"}); err != nil {
+		t.Errorf("Testing integrity of unique keys. Expected the value to be free after repair, got %v", err)
+	}
+}
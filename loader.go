@@ -0,0 +1,303 @@
+package tormenta
+
+import (
+	"fmt"
+
+	"github.com/dgraph-io/badger"
+	"github.com/jpincas/gouuidv6"
+)
+
+// loadBatchSize is the number of records the loader writes in each batch
+const loadBatchSize = 1000
+
+const (
+	errLoadFailures = "%v record(s) failed to load - first failure was record %v (ID %v): %v"
+)
+
+// LoadError describes the failure to load a single record
+type LoadError struct {
+	// Position is the position of the record in the sequence of records
+	// given to the loader, counting from 0
+	Position int
+	ID       gouuidv6.UUID
+	Err      error
+}
+
+// LoadErrors is returned by the bulk loader when one or more records
+// could not be loaded.  The rest of the records will have been loaded regardless.
+type LoadErrors []LoadError
+
+func (e LoadErrors) Error() string {
+	if len(e) == 0 {
+		return ""
+	}
+
+	return fmt.Sprintf(errLoadFailures, len(e), e[0].Position, e[0].ID, e[0].Err)
+}
+
+// Loader is a high throughput, streaming bulk loader built on Badger's WriteBatch.
+// Records are written in batches of 1000, so it is much faster than Save for large numbers of records,
+// and not subject to Badger's maximum transaction size.  There is no atomicity though:
+// each record is loaded independently and failures are reported per record.
+// PreSave, Validate and PostSave hooks are run as normal, but the DB passed to PreSave
+// is not bound to any transaction, and a record whose PostSave fails
+// will already have been written by the time the failure is reported.
+// Subscribers (see Subscribe) get the changes of each batch once it has been written.
+// Unique fields are checked against the DB and the rest of the batch, but the check isn't
+// protected by a transaction, so a record saved or loaded at the same time with the same
+// unique value can get through.  Don't load records with unique fields while they are being written elsewhere.
+type Loader struct {
+	db        DB
+	wb        *batchWriter
+	assumeNew bool
+	position  int
+	counter   int
+	errs      LoadErrors
+
+	// The number of records in the current batch, and a copy of each of them by content key.
+	// Lookups of existing records only see batches that have been written,
+	// so a record added twice in the same batch is found here instead
+	batched int
+	written map[string]Record
+
+	// Changes for subscribers, sent as each batch is written
+	changes []Change
+
+	// The unique keys written in the current batch, which won't be visible
+	// to the DB until the batch is written
+	uniqueKeys map[string]gouuidv6.UUID
+}
+
+// batchWriter wraps a WriteBatch to keep hold of the first error,
+// so that we can tell the difference between a record that failed to load
+// and a batch that failed to write
+type batchWriter struct {
+	wb  *badger.WriteBatch
+	err error
+}
+
+func (b *batchWriter) SetEntry(e *badger.Entry) error {
+	if err := b.wb.SetEntry(e); err != nil {
+		b.err = err
+		return err
+	}
+
+	return nil
+}
+
+func (b *batchWriter) Delete(key []byte) error {
+	if err := b.wb.Delete(key); err != nil {
+		b.err = err
+		return err
+	}
+
+	return nil
+}
+
+// NewLoader sets up a new bulk loader.  Add records to it with Add,
+// and don't forget to Close it when you're done, so that the final batch gets written
+func (db DB) NewLoader() *Loader {
+	return &Loader{
+		db:         db,
+		wb:         &batchWriter{wb: db.KV.NewWriteBatch()},
+		written:    map[string]Record{},
+		uniqueKeys: map[string]gouuidv6.UUID{},
+	}
+}
+
+// AssumeNew tells the loader that every record is new, even if its ID is already set
+// (e.g. when importing historical records with date-based IDs).
+// This skips looking up the existing version of each record, which makes loading faster,
+// but if a record does already exist, its old index keys will be left behind.
+// Records with no ID are always known to be new, and are never looked up.
+func (l *Loader) AssumeNew() *Loader {
+	l.assumeNew = true
+	return l
+}
+
+// Add queues records for loading.  A record that can't be loaded is recorded as a failure
+// and loading continues.  An error is only returned if writing to the DB fails,
+// in which case the loader can no longer be used.
+func (l *Loader) Add(records ...Record) error {
+	for i := 0; i < len(records); i++ {
+		record := records[i]
+		position := l.position
+		l.position++
+
+		op := Operation{
+			Type:    SaveOperation,
+			KeyRoot: KeyRootString(record),
+			Entity:  record,
+		}
+
+		var moreRecordsToLoad []Record
+		err := l.db.run(op, func(Operation) (err error) {
+			moreRecordsToLoad, err = l.load(record)
+			return
+		})
+
+		// A failure of the write batch means we can't carry on
+		if l.wb.err != nil {
+			return l.wb.err
+		}
+
+		if err != nil {
+			l.errs = append(l.errs, LoadError{
+				Position: position,
+				ID:       record.GetID(),
+				Err:      err,
+			})
+			continue
+		}
+
+		l.counter++
+
+		// Records returned by the presave trigger are loaded as well
+		records = append(records, moreRecordsToLoad...)
+
+		l.batched++
+		if l.batched == loadBatchSize {
+			if err := l.flush(); err != nil {
+				return err
+			}
+
+			l.wb = &batchWriter{wb: l.db.KV.NewWriteBatch()}
+		}
+	}
+
+	return nil
+}
+
+// flush writes the current batch and sends its changes to subscribers
+func (l *Loader) flush() error {
+	if err := l.wb.wb.Flush(); err != nil {
+		return err
+	}
+
+	l.db.feed.publish(l.changes)
+
+	l.batched = 0
+	l.written = map[string]Record{}
+	l.uniqueKeys = map[string]gouuidv6.UUID{}
+	l.changes = nil
+	return nil
+}
+
+func (l *Loader) load(record Record) ([]Record, error) {
+	var previous Record
+
+	// Unless we know the record is new, we need to get the old version
+	// in order to reindex it - either from the current batch, or from the DB
+	if pending, ok := l.written[writtenKey(record)]; ok {
+		if err := checkVersion(record, pending); err != nil {
+			return nil, err
+		}
+
+		previous = pending
+	} else if !l.assumeNew && !record.GetID().IsNil() {
+		var found bool
+		old := newRecord(record)
+		if err := l.db.view(func(txn *badger.Txn) (err error) {
+			found, err = l.db.get(txn, old, noCTX, record.GetID())
+			return
+		}); err != nil {
+			return nil, err
+		}
+
+		if found {
+			if err := checkVersion(record, old); err != nil {
+				return nil, err
+			}
+
+			previous = old
+		}
+	}
+
+	moreRecordsToLoad, err := preSave(l.db, record)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := l.checkUnique(record); err != nil {
+		return nil, err
+	}
+
+	if err := l.db.write(l.wb, record, previous); err != nil {
+		return nil, err
+	}
+
+	l.written[writtenKey(record)] = copyRecord(record)
+
+	for _, key := range uniqueKeyBytes(record) {
+		l.uniqueKeys[string(key)] = record.GetID()
+	}
+
+	if l.db.feed.active() {
+		l.changes = append(l.changes, saveChange(record, previous))
+	}
+
+	return moreRecordsToLoad, nil
+}
+
+func writtenKey(record Record) string {
+	return string(newContentKey(KeyRoot(record), record.GetID()).bytes())
+}
+
+// checkUnique checks unique fields against both the DB
+// and the records in the current batch.
+// Badger doesn't detect conflicts with write batches, so this can't catch a clash
+// with a record being saved at the same time (see Loader)
+func (l *Loader) checkUnique(record Record) error {
+	for _, k := range uniqueKeys(record) {
+		if id, ok := l.uniqueKeys[string(k.key)]; ok && id != record.GetID() {
+			return ErrUniqueViolation{
+				KeyRoot:       KeyRootString(record),
+				Field:         k.name,
+				ConflictingID: id,
+			}
+		}
+	}
+
+	return l.db.view(func(txn *badger.Txn) error {
+		return checkUnique(txn, record)
+	})
+}
+
+// Errors returns the failures so far
+func (l *Loader) Errors() LoadErrors {
+	return l.errs
+}
+
+// Close writes any outstanding records and returns the total number of records loaded.
+// If any records failed to load, the error will be LoadErrors, listing them.
+func (l *Loader) Close() (int, error) {
+	if err := l.flush(); err != nil {
+		return l.counter, err
+	}
+
+	if len(l.errs) > 0 {
+		return l.counter, l.errs
+	}
+
+	return l.counter, nil
+}
+
+// Cancel abandons the loader.  Records already written in previous batches stay written,
+// and their changes will already have been sent to subscribers.  The current batch is dropped,
+// and no changes are sent for it (although Badger may have written part of a very large batch already).
+func (l *Loader) Cancel() {
+	l.wb.wb.Cancel()
+}
+
+// BulkLoad loads records using a Loader.  See Loader for the differences to Save.
+// The number of records loaded is returned, and if any failed to load, the error will be LoadErrors, listing them.
+func (db DB) BulkLoad(records ...Record) (int, error) {
+	l := db.NewLoader()
+
+	if err := l.Add(records...); err != nil {
+		l.Cancel()
+		return l.counter, err
+	}
+
+	return l.Close()
+}
@@ -0,0 +1,112 @@
+package tormenta_test
+
+import (
+	"testing"
+
+	"github.com/jpincas/tormenta"
+	"github.com/jpincas/tormenta/testtypes"
+)
+
+func Test_BulkLoad(t *testing.T) {
+	db, _ := tormenta.OpenTestWithOptions("data/tests", testDBOptions)
+	defer db.Close()
+
+	noRecords := 5000
+
+	var toLoad []tormenta.Record
+	for i := 0; i < noRecords; i++ {
+		toLoad = append(toLoad, &testtypes.MiniStruct{IntField: i % 10})
+	}
+
+	n, err := db.BulkLoad(toLoad...)
+	if err != nil {
+		t.Fatalf("Testing bulk load. Got error %v", err)
+	}
+
+	if n != noRecords {
+		t.Errorf("Testing bulk load. Expected %v records loaded, got %v", noRecords, n)
+	}
+
+	var results []testtypes.MiniStruct
+	if c, _ := db.Find(&results).Count(); c != noRecords {
+		t.Errorf("Testing query after bulk load. Expected %v results, got %v", noRecords, c)
+	}
+
+	if c, _ := db.Find(&results).Match("IntField", 3).Count(); c != noRecords/10 {
+		t.Errorf("Testing index match after bulk load. Expected %v results, got %v", noRecords/10, c)
+	}
+
+	// Loading an existing record again should replace its index keys
+	first := toLoad[0].(*testtypes.MiniStruct)
+	first.IntField = 100
+	if _, err := db.BulkLoad(first); err != nil {
+		t.Errorf("Testing bulk load of existing record. Got error %v", err)
+	}
+
+	if c, _ := db.Find(&results).Match("IntField", 0).Count(); c != noRecords/10-1 {
+		t.Errorf("Testing old index after bulk load of existing record. Expected %v results, got %v", noRecords/10-1, c)
+	}
+
+	if c, _ := db.Find(&results).Match("IntField", 100).Count(); c != 1 {
+		t.Errorf("Testing new index after bulk load of existing record. Expected 1 result, got %v", c)
+	}
+}
+
+func Test_BulkLoad_SameRecordTwice(t *testing.T) {
+	db, _ := tormenta.OpenTestWithOptions("data/tests", testDBOptions)
+	defer db.Close()
+
+	// Both loads go in the same batch, so the second one can't find
+	// the first in the DB, but should still replace its index keys
+	record := testtypes.MiniStruct{IntField: 1}
+	l := db.NewLoader()
+	l.Add(&record)
+	record.IntField = 2
+	l.Add(&record)
+
+	if n, err := l.Close(); n != 2 || err != nil {
+		t.Errorf("Testing bulk load of the same record twice. Expected 2 records loaded, got %v (error %v)", n, err)
+	}
+
+	var results []testtypes.MiniStruct
+	if c, _ := db.Find(&results).Match("IntField", 1).Count(); c != 0 {
+		t.Errorf("Testing old index after bulk load of the same record twice. Expected no results, got %v", c)
+	}
+
+	if c, _ := db.Find(&results).Match("IntField", 2).Count(); c != 1 {
+		t.Errorf("Testing new index after bulk load of the same record twice. Expected 1 result, got %v", c)
+	}
+}
+
+func Test_BulkLoad_Failures(t *testing.T) {
+	db, _ := tormenta.OpenTestWithOptions("data/tests", testDBOptions)
+	defer db.Close()
+
+	l := db.NewLoader()
+	if err := l.Add(
+		&testtypes.FullStruct{IntField: 1},
+		&testtypes.FullStruct{IntField: 2, ShouldBlockSave: true},
+		&testtypes.FullStruct{IntField: 3},
+	); err != nil {
+		t.Fatalf("Testing loader add. Got error %v", err)
+	}
+
+	n, err := l.Close()
+	if n != 2 {
+		t.Errorf("Testing bulk load with failures. Expected 2 records loaded, got %v", n)
+	}
+
+	loadErrs, ok := err.(tormenta.LoadErrors)
+	if !ok {
+		t.Fatalf("Testing bulk load with failures. Expected LoadErrors, got %v", err)
+	}
+
+	if len(loadErrs) != 1 || loadErrs[0].Position != 1 {
+		t.Errorf("Testing bulk load with failures. Expected a single failure at position 1, got %+v", loadErrs)
+	}
+
+	var results []testtypes.FullStruct
+	if c, _ := db.Find(&results).Count(); c != 2 {
+		t.Errorf("Testing query after bulk load with failures. Expected 2 results, got %v", c)
+	}
+}
@@ -0,0 +1,103 @@
+package tormenta
+
+import (
+	"context"
+	"sync"
+)
+
+// OperationType says which kind of operation is passing through the middleware
+type OperationType int
+
+const (
+	SaveOperation OperationType = iota + 1
+	DeleteOperation
+	GetOperation
+	QueryOperation
+	SoftDeleteOperation
+	RestoreOperation
+)
+
+func (o OperationType) String() string {
+	switch o {
+	case SaveOperation:
+		return "save"
+	case DeleteOperation:
+		return "delete"
+	case GetOperation:
+		return "get"
+	case QueryOperation:
+		return "query"
+	case SoftDeleteOperation:
+		return "softdelete"
+	case RestoreOperation:
+		return "restore"
+	}
+
+	return ""
+}
+
+// Operation describes an operation passing through the middleware
+type Operation struct {
+	Type OperationType
+
+	// KeyRoot identifies the entity type
+	KeyRoot string
+
+	// Entity is the entity being saved, deleted, soft deleted or restored (once per entity),
+	// or retrieved with Get.  Before calling the next handler, only the ID
+	// of an entity being retrieved is set.  It is nil for queries and GetIDs
+	Entity Record
+
+	// Target is the pointer passed to a query or GetIDs, onto which results are set
+	Target interface{}
+
+	// Query is the query being executed, for queries
+	Query *Query
+
+	// Ctx is the pass-through context of gets and queries (see GetWithContext and SetContext)
+	Ctx map[string]interface{}
+
+	// Context is the standard library context of the operation, if there is one (see SaveCtx, RunCtx etc)
+	Context context.Context
+}
+
+// Handler carries out an operation
+type Handler func(Operation) error
+
+// Middleware wraps every save, delete, soft delete, restore, get and query, whatever the entity type.
+// It receives the next handler in the chain, and returns a handler which will usually call it.
+// A middleware can modify the entity or query before calling next,
+// reject the operation by returning an error instead of calling next,
+// or observe the outcome after next returns
+type Middleware func(next Handler) Handler
+
+type middlewareChain struct {
+	mu          sync.RWMutex
+	middlewares []Middleware
+}
+
+// Use adds middleware to the DB.  Middleware added first runs outermost.
+// It applies to all copies of the DB, including those bound to transactions
+func (db DB) Use(middlewares ...Middleware) {
+	db.middleware.mu.Lock()
+	defer db.middleware.mu.Unlock()
+	db.middleware.middlewares = append(db.middleware.middlewares, middlewares...)
+}
+
+// run passes an operation through the middleware, ending with fn
+func (db DB) run(op Operation, fn Handler) error {
+	if db.middleware == nil {
+		return fn(op)
+	}
+
+	db.middleware.mu.RLock()
+	middlewares := db.middleware.middlewares
+	db.middleware.mu.RUnlock()
+
+	handler := fn
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		handler = middlewares[i](handler)
+	}
+
+	return handler(op)
+}
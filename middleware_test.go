@@ -0,0 +1,118 @@
+package tormenta_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/jpincas/tormenta"
+	"github.com/jpincas/tormenta/testtypes"
+)
+
+func Test_Middleware(t *testing.T) {
+	db, _ := tormenta.OpenTestWithOptions("data/tests", testDBOptions)
+	defer db.Close()
+
+	var observed []string
+
+	// Observe everything
+	db.Use(func(next tormenta.Handler) tormenta.Handler {
+		return func(op tormenta.Operation) error {
+			err := next(op)
+			observed = append(observed, op.Type.String()+":"+op.KeyRoot)
+			return err
+		}
+	})
+
+	// Stamp every ministruct that is saved
+	db.Use(func(next tormenta.Handler) tormenta.Handler {
+		return func(op tormenta.Operation) error {
+			if miniStruct, ok := op.Entity.(*testtypes.MiniStruct); ok && op.Type == tormenta.SaveOperation {
+				miniStruct.StringField = "stamped"
+			}
+
+			return next(op)
+		}
+	})
+
+	miniStruct := testtypes.MiniStruct{IntField: 1}
+	db.Save(&miniStruct)
+	db.Get(&testtypes.MiniStruct{}, miniStruct.ID)
+	db.Find(&[]testtypes.MiniStruct{}).Run()
+	db.SoftDelete(&testtypes.MiniStruct{}, miniStruct.ID)
+	db.Restore(&testtypes.MiniStruct{}, miniStruct.ID)
+	db.Delete(&miniStruct)
+
+	expected := []string{"save:ministruct", "get:ministruct", "query:ministruct", "softdelete:ministruct", "restore:ministruct", "delete:ministruct"}
+	if len(observed) != len(expected) {
+		t.Fatalf("Testing observing middleware. Expected %v, got %v", expected, observed)
+	}
+
+	for i := range expected {
+		if observed[i] != expected[i] {
+			t.Errorf("Testing observing middleware. Expected %v, got %v", expected, observed)
+			break
+		}
+	}
+
+	if miniStruct.StringField != "stamped" {
+		t.Error("Testing modifying middleware. Entity was not stamped")
+	}
+}
+
+func Test_Middleware_Reject(t *testing.T) {
+	db, _ := tormenta.OpenTestWithOptions("data/tests", testDBOptions)
+	defer db.Close()
+
+	existing := testtypes.MiniStruct{IntField: 1}
+	hidden := testtypes.MiniStruct{IntField: -5}
+	db.Save(&existing, &hidden)
+
+	errNotAllowed := errors.New("not allowed")
+
+	// Only allow access to records with a positive IntField
+	db.Use(func(next tormenta.Handler) tormenta.Handler {
+		return func(op tormenta.Operation) error {
+			if miniStruct, ok := op.Entity.(*testtypes.MiniStruct); ok && op.Type == tormenta.SaveOperation {
+				if miniStruct.IntField < 0 {
+					return errNotAllowed
+				}
+			}
+
+			if op.Type == tormenta.DeleteOperation || op.Type == tormenta.SoftDeleteOperation {
+				return errNotAllowed
+			}
+
+			// Restrict queries
+			if op.Type == tormenta.QueryOperation {
+				op.Query.Range("IntField", 0, nil)
+			}
+
+			return next(op)
+		}
+	})
+
+	if _, err := db.Save(&testtypes.MiniStruct{IntField: 2}, &testtypes.MiniStruct{IntField: -1}); err != errNotAllowed {
+		t.Errorf("Testing rejecting middleware on save. Expected errNotAllowed, got %v", err)
+	}
+
+	if err := db.Delete(&testtypes.MiniStruct{}, existing.ID); err != errNotAllowed {
+		t.Errorf("Testing rejecting middleware on delete. Expected errNotAllowed, got %v", err)
+	}
+
+	if err := db.SoftDelete(&testtypes.MiniStruct{}, existing.ID); err != errNotAllowed {
+		t.Errorf("Testing rejecting middleware on soft delete. Expected errNotAllowed, got %v", err)
+	}
+
+	if _, err := db.BulkLoad(&testtypes.MiniStruct{IntField: -1}); err == nil {
+		t.Error("Testing rejecting middleware on bulk load. Expected an error but did not get one")
+	}
+
+	if n, _ := db.Find(&[]testtypes.MiniStruct{}).Count(); n != 1 {
+		t.Errorf("Testing query modifying middleware. Expected 1 result, got %v", n)
+	}
+
+	// Updates by query are restricted in the same way, so the hidden record isn't touched
+	if n, err := db.Find(&[]testtypes.MiniStruct{}).Update(map[string]interface{}{"StringField": "updated"}); n != 1 || err != nil {
+		t.Errorf("Testing query modifying middleware on update by query. Expected 1 record updated, got %v (error %v)", n, err)
+	}
+}
@@ -0,0 +1,205 @@
+// Package migrate provides ready made migrations for the common changes to entity structs:
+// renaming, retyping and splitting fields.  Each helper returns a tormenta.Migration,
+// which needs a version before it is run, e.g.
+//
+//	db.Migrate(
+//		migrate.RenameField(&Order{}, "Amt", "Amount").WithVersion(1),
+//		migrate.ConvertField(&Order{}, "Qty", int64(0)).WithVersion(2),
+//	)
+//
+// Stored records are rewritten, then the indexes of the entity type are rebuilt,
+// which drops the keys of the old fields and writes the keys of the new ones.
+// Field names are the names of the struct fields.  Records stored with SerialiseFunc are keyed
+// by those names, and records stored with a codec by their JSON names if they have them,
+// so each record is looked up under whichever it has, and new fields are written the same way.
+// Old fields that no longer exist on the struct are looked for under the name given.
+package migrate
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/jpincas/tormenta"
+)
+
+const (
+	ErrCannotConvert = "Field %s: %v (%T) cannot be converted to %s"
+)
+
+// RenameField moves the stored values of a field to a new name.
+// Records without the old field are left as they are
+func RenameField(entity tormenta.Record, oldName, newName string) tormenta.Migration {
+	return tormenta.Migration{
+		Entity: entity,
+		Map: func(record map[string]interface{}) error {
+			oldKey := storedKey(record, entity, oldName)
+			value, ok := record[oldKey]
+			if !ok {
+				return nil
+			}
+
+			delete(record, oldKey)
+			record[storedKey(record, entity, newName)] = value
+			return nil
+		},
+	}
+}
+
+// ConvertField changes the type of the stored values of a field to the type of the example given,
+// e.g. ConvertField(&Order{}, "Qty", int64(0)).  Numbers, strings and bools can be converted
+// between each other where it makes sense.  Numbers are only converted if nothing is lost,
+// so e.g. 2.5 can't become an int.  A value that can't be converted stops the migration
+func ConvertField(entity tormenta.Record, fieldName string, example interface{}) tormenta.Migration {
+	t := reflect.TypeOf(example)
+
+	return tormenta.Migration{
+		Entity: entity,
+		Map: func(record map[string]interface{}) error {
+			key := storedKey(record, entity, fieldName)
+			value, ok := record[key]
+			if !ok || value == nil {
+				return nil
+			}
+
+			converted, ok := convert(value, t)
+			if !ok {
+				return fmt.Errorf(ErrCannotConvert, fieldName, value, value, t)
+			}
+
+			record[key] = converted
+			return nil
+		},
+	}
+}
+
+// SplitField replaces a field with the fields returned by split, keyed by field name,
+// e.g. a full name into first and last names.
+// split is only called for records which have the field
+func SplitField(entity tormenta.Record, fieldName string, split func(value interface{}) (map[string]interface{}, error)) tormenta.Migration {
+	return tormenta.Migration{
+		Entity: entity,
+		Map: func(record map[string]interface{}) error {
+			key := storedKey(record, entity, fieldName)
+			value, ok := record[key]
+			if !ok {
+				return nil
+			}
+
+			fields, err := split(value)
+			if err != nil {
+				return err
+			}
+
+			delete(record, key)
+			for name, value := range fields {
+				record[storedKey(record, entity, name)] = value
+			}
+
+			return nil
+		},
+	}
+}
+
+// storedKey works out the key a field has in a stored record: its name if the record has it,
+// otherwise its JSON name if the record has that.  For a field the record doesn't have yet,
+// it is the name the record's other fields go by - the Go names for records stored with SerialiseFunc,
+// which have the ID under "ID", or the JSON names for records stored with a codec
+func storedKey(record map[string]interface{}, entity tormenta.Record, fieldName string) string {
+	if _, ok := record[fieldName]; ok {
+		return fieldName
+	}
+
+	jsonName := jsonName(entity, fieldName)
+	if _, ok := record[jsonName]; ok {
+		return jsonName
+	}
+
+	if _, ok := record["ID"]; ok {
+		return fieldName
+	}
+
+	return jsonName
+}
+
+// jsonName is the JSON name of a field, which is its name unless it is tagged otherwise
+func jsonName(entity tormenta.Record, fieldName string) string {
+	field, ok := reflect.Indirect(reflect.ValueOf(entity)).Type().FieldByName(fieldName)
+	if !ok {
+		return fieldName
+	}
+
+	name := strings.Split(field.Tag.Get("json"), ",")[0]
+	if name == "" || name == "-" {
+		return fieldName
+	}
+
+	return name
+}
+
+var typeFloat64 = reflect.TypeOf(float64(0))
+
+// convert turns a stored value into the given type, if it can be done safely
+func convert(value interface{}, t reflect.Type) (interface{}, bool) {
+	v := reflect.ValueOf(value)
+
+	switch {
+	case tormenta.IsNumberKind(t.Kind()):
+		var f float64
+		switch x := value.(type) {
+		case string:
+			parsed, err := strconv.ParseFloat(strings.TrimSpace(x), 64)
+			if err != nil {
+				return nil, false
+			}
+
+			f = parsed
+		case bool:
+			if x {
+				f = 1
+			}
+		default:
+			if !tormenta.IsNumberKind(v.Kind()) {
+				return nil, false
+			}
+
+			f = v.Convert(typeFloat64).Float()
+		}
+
+		// A round trip tells us if anything was lost
+		converted := reflect.ValueOf(f).Convert(t)
+		if converted.Convert(typeFloat64).Float() != f {
+			return nil, false
+		}
+
+		return converted.Interface(), true
+
+	case t.Kind() == reflect.String:
+		switch x := value.(type) {
+		case float64:
+			return reflect.ValueOf(strconv.FormatFloat(x, 'f', -1, 64)).Convert(t).Interface(), true
+		case bool:
+			return reflect.ValueOf(strconv.FormatBool(x)).Convert(t).Interface(), true
+		}
+
+	case t.Kind() == reflect.Bool:
+		switch x := value.(type) {
+		case string:
+			parsed, err := strconv.ParseBool(strings.TrimSpace(x))
+			if err != nil {
+				return nil, false
+			}
+
+			return reflect.ValueOf(parsed).Convert(t).Interface(), true
+		case float64:
+			return reflect.ValueOf(x != 0).Convert(t).Interface(), true
+		}
+	}
+
+	if v.Type().ConvertibleTo(t) && v.Kind() == t.Kind() {
+		return v.Convert(t).Interface(), true
+	}
+
+	return nil, false
+}
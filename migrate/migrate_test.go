@@ -0,0 +1,199 @@
+package migrate_test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/dgraph-io/badger"
+	"github.com/jpincas/gouuidv6"
+	"github.com/jpincas/tormenta"
+	"github.com/jpincas/tormenta/migrate"
+)
+
+type Order struct {
+	tormenta.Model
+
+	Customer string
+	Amount   float64 `json:"amount"`
+	Qty      int64   `json:"qty"`
+}
+
+type Person struct {
+	tormenta.Model
+
+	First string
+	Last  string `json:"last"`
+}
+
+// legacy makes stored records look like they were saved by an older version of the struct
+func legacy(entity tormenta.Record, version int, fn func(record map[string]interface{})) tormenta.Migration {
+	return tormenta.Migration{
+		Entity:  entity,
+		Version: version,
+		Map: func(record map[string]interface{}) error {
+			fn(record)
+			return nil
+		},
+	}
+}
+
+// stored gets the record as it is stored
+func stored(t *testing.T, db *tormenta.DB, id gouuidv6.UUID) map[string]interface{} {
+	value, _, _ := db.GetRaw("order", id)
+	record := map[string]interface{}{}
+	if err := json.Unmarshal(value, &record); err != nil {
+		t.Fatalf("Couldn't decode stored record %s: %v", value, err)
+	}
+
+	return record
+}
+
+func Test_RenameField(t *testing.T) {
+	db, _ := tormenta.OpenTest("data/tests")
+	defer db.Close()
+
+	order := Order{Customer: "jon", Amount: 9.99}
+	db.Save(&order)
+
+	// Records stored with the old name, and their index keys.
+	// SerialiseFunc stores records by Go field names
+	oldIndexKey := tormenta.MakeIndexKey([]byte("order"), order.ID, []byte("Amt"), 9.99)
+	db.Migrate(legacy(&Order{}, 1, func(record map[string]interface{}) {
+		record["Amt"] = record["Amount"]
+		delete(record, "Amount")
+	}))
+	db.KV.Update(func(txn *badger.Txn) error {
+		return txn.Set(oldIndexKey, []byte{})
+	})
+
+	if record := stored(t, db, order.ID); record["Amt"] != 9.99 || record["Amount"] != nil {
+		t.Fatalf("Testing rename field. Expected the legacy record to have Amt only, got %v", record)
+	}
+
+	if err := db.Migrate(migrate.RenameField(&Order{}, "Amt", "Amount").WithVersion(2)); err != nil {
+		t.Fatalf("Testing rename field. Got error %v", err)
+	}
+
+	if record := stored(t, db, order.ID); record["Amount"] != 9.99 || record["Amt"] != nil || record["amount"] != nil {
+		t.Errorf("Testing rename field. Expected the record to be stored with Amount, got %v", record)
+	}
+
+	result := Order{}
+	db.Get(&result, order.ID)
+	if result.Amount != 9.99 {
+		t.Errorf("Testing rename field. Expected the amount to be 9.99, got %v", result.Amount)
+	}
+
+	if n, _ := db.Find(&[]Order{}).Match("Amount", 9.99).Count(); n != 1 {
+		t.Errorf("Testing rename field. Expected the new field to be indexed, got %v results", n)
+	}
+
+	db.KV.View(func(txn *badger.Txn) error {
+		if _, err := txn.Get(oldIndexKey); err != badger.ErrKeyNotFound {
+			t.Error("Testing rename field. Expected the old index key to be gone")
+		}
+
+		return nil
+	})
+}
+
+func Test_ConvertField(t *testing.T) {
+	db, _ := tormenta.OpenTest("data/tests")
+	defer db.Close()
+
+	order := Order{Customer: "jon"}
+	db.Save(&order)
+
+	// Quantities used to be strings
+	db.Migrate(legacy(&Order{}, 1, func(record map[string]interface{}) {
+		record["Qty"] = "12"
+	}))
+
+	if err := db.Migrate(migrate.ConvertField(&Order{}, "Qty", int64(0)).WithVersion(2)); err != nil {
+		t.Fatalf("Testing convert field. Got error %v", err)
+	}
+
+	if record := stored(t, db, order.ID); record["Qty"] != float64(12) || record["qty"] != nil {
+		t.Errorf("Testing convert field. Expected the stored quantity to be the number 12, got %v", record)
+	}
+
+	result := Order{}
+	if _, err := db.Get(&result, order.ID); err != nil || result.Qty != 12 {
+		t.Errorf("Testing convert field. Expected the quantity to be 12, got %v (error %v)", result.Qty, err)
+	}
+
+	if n, _ := db.Find(&[]Order{}).Match("Qty", 12).Count(); n != 1 {
+		t.Errorf("Testing convert field. Expected the converted field to be indexed, got %v results", n)
+	}
+
+	// Values that don't fit are an error
+	db.Migrate(legacy(&Order{}, 3, func(record map[string]interface{}) {
+		record["Qty"] = 2.5
+	}))
+
+	if err := db.Migrate(migrate.ConvertField(&Order{}, "Qty", int64(0)).WithVersion(4)); err == nil {
+		t.Error("Testing convert field. Expected an error converting 2.5 to an int64")
+	}
+}
+
+func Test_SplitField(t *testing.T) {
+	db, _ := tormenta.OpenTest("data/tests")
+	defer db.Close()
+
+	person := Person{}
+	db.Save(&person)
+
+	// Names used to be stored whole
+	db.Migrate(legacy(&Person{}, 1, func(record map[string]interface{}) {
+		record["Name"] = "Jon Smith"
+	}))
+
+	split := migrate.SplitField(&Person{}, "Name", func(value interface{}) (map[string]interface{}, error) {
+		names := strings.SplitN(value.(string), " ", 2)
+		return map[string]interface{}{"First": names[0], "Last": names[1]}, nil
+	})
+
+	if err := db.Migrate(split.WithVersion(2)); err != nil {
+		t.Fatalf("Testing split field. Got error %v", err)
+	}
+
+	result := Person{}
+	db.Get(&result, person.ID)
+	if result.First != "Jon" || result.Last != "Smith" {
+		t.Errorf("Testing split field. Expected Jon Smith, got %s %s", result.First, result.Last)
+	}
+
+	if n, _ := db.Find(&[]Person{}).Match("Last", "smith").Count(); n != 1 {
+		t.Errorf("Testing split field. Expected the new fields to be indexed, got %v results", n)
+	}
+}
+
+func Test_RenameField_Codec(t *testing.T) {
+	options := tormenta.DefaultOptions
+	options.Codec = tormenta.JSONCodec
+	db, _ := tormenta.OpenTestWithOptions("data/tests", options)
+	defer db.Close()
+
+	order := Order{Customer: "jon", Amount: 9.99}
+	db.Save(&order)
+
+	// Codecs store records by JSON names
+	db.Migrate(legacy(&Order{}, 1, func(record map[string]interface{}) {
+		record["Amt"] = record["amount"]
+		delete(record, "amount")
+	}))
+
+	if err := db.Migrate(migrate.RenameField(&Order{}, "Amt", "Amount").WithVersion(2)); err != nil {
+		t.Fatalf("Testing rename field with a codec. Got error %v", err)
+	}
+
+	if record := stored(t, db, order.ID); record["amount"] != 9.99 || record["Amt"] != nil || record["Amount"] != nil {
+		t.Errorf("Testing rename field with a codec. Expected the record to be stored with amount, got %v", record)
+	}
+
+	result := Order{}
+	if db.Get(&result, order.ID); result.Amount != 9.99 {
+		t.Errorf("Testing rename field with a codec. Expected the amount to be 9.99, got %v", result.Amount)
+	}
+}
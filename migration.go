@@ -0,0 +1,239 @@
+package tormenta
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"sort"
+
+	"github.com/dgraph-io/badger"
+)
+
+// Metadata key format
+// m:root:migrationversion
+// m:root:migrating
+
+const (
+	metadataKeyPrefix = "m"
+
+	metadataMigrationVersion = "migrationversion"
+	metadataMigrating        = "migrating"
+
+	ErrMigrationTransform = "Migration %v of %s must have exactly one of Map or Raw"
+	ErrMigrationVersion   = "Migration %v of %s has an invalid version - versions must be unique and greater than 0"
+)
+
+// Migration transforms the stored records of an entity type, e.g. after a field
+// has been renamed or changed type.  It works on the stored records directly,
+// so they don't have to match the current struct.
+// Each record is transformed either as a map, with Map,
+// or as the raw bytes stored for it, with Raw
+type Migration struct {
+	// Entity is the entity type to migrate, e.g. &Order{}
+	Entity Record
+
+	// Version identifies the migration.  Migrations of an entity type
+	// are run in order of version, and only once - the latest version applied
+	// is recorded in the DB, and migrations up to it are skipped.
+	Version int
+
+	// Map changes a record, which is given as a map decoded by the DB's
+	// unserialise function or the record's codec (so with JSON, numbers are float64s).
+	// It doesn't work for records stored with gob
+	Map func(record map[string]interface{}) error
+
+	// Raw turns the stored bytes of a record into new ones.
+	// For records stored with a codec, the bytes are those encoded by the codec,
+	// without the codec identifier
+	Raw func(value []byte) ([]byte, error)
+}
+
+// WithVersion returns a copy of the migration with the given version,
+// which is handy for migrations built by helpers, e.g.
+// db.Migrate(migrate.RenameField(&Order{}, "Amt", "Amount").WithVersion(3))
+func (m Migration) WithVersion(version int) Migration {
+	m.Version = version
+	return m
+}
+
+// transform migrates a stored value, which keeps the codec it was stored with
+func (m Migration) transform(db DB, value []byte) ([]byte, error) {
+	codec, data, err := splitValue(value)
+	if err != nil {
+		return nil, err
+	}
+
+	if m.Raw != nil {
+		transformed, err := m.Raw(data)
+		if err != nil {
+			return nil, err
+		}
+
+		return joinValue(codec, transformed)
+	}
+
+	record := map[string]interface{}{}
+	if err := db.unmarshal(codec, data, &record); err != nil {
+		return nil, err
+	}
+
+	if err := m.Map(record); err != nil {
+		return nil, err
+	}
+
+	return db.marshal(codec, record)
+}
+
+// Migrate runs the migrations which haven't been run yet, in order of version for each entity type.
+// Records are migrated in chunks, each in its own transaction along with a note of how far the migration has got,
+// so if it is interrupted, running it again carries on where it left off.
+// Once all the migrations of an entity type have been run, its indexes are rebuilt (see RebuildIndexes).
+// Like other maintenance operations, it is best run while nothing else is writing to the DB
+func (db DB) Migrate(migrations ...Migration) error {
+	// Group the migrations by entity type, keeping the types in the order given
+	var keyRoots []string
+	byKeyRoot := map[string][]Migration{}
+
+	for _, m := range migrations {
+		keyRoot := KeyRootString(m.Entity)
+
+		if (m.Map == nil) == (m.Raw == nil) {
+			return fmt.Errorf(ErrMigrationTransform, m.Version, keyRoot)
+		}
+
+		if m.Version <= 0 {
+			return fmt.Errorf(ErrMigrationVersion, m.Version, keyRoot)
+		}
+
+		for _, other := range byKeyRoot[keyRoot] {
+			if other.Version == m.Version {
+				return fmt.Errorf(ErrMigrationVersion, m.Version, keyRoot)
+			}
+		}
+
+		if _, ok := byKeyRoot[keyRoot]; !ok {
+			keyRoots = append(keyRoots, keyRoot)
+		}
+
+		byKeyRoot[keyRoot] = append(byKeyRoot[keyRoot], m)
+	}
+
+	for _, keyRoot := range keyRoots {
+		toRun := byKeyRoot[keyRoot]
+		sort.Slice(toRun, func(i, j int) bool {
+			return toRun[i].Version < toRun[j].Version
+		})
+
+		current, err := db.MigrationVersion(toRun[0].Entity)
+		if err != nil {
+			return err
+		}
+
+		migrated := false
+		for _, m := range toRun {
+			if m.Version <= current {
+				continue
+			}
+
+			if err := db.migrate(m); err != nil {
+				return err
+			}
+
+			migrated = true
+		}
+
+		if migrated {
+			if _, err := db.RebuildIndexes(toRun[0].Entity, nil); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// MigrationVersion returns the version of the latest migration applied to an entity type,
+// or 0 if there hasn't been one
+func (db DB) MigrationVersion(entity Record) (version int, err error) {
+	err = db.KV.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(metadataKey(KeyRoot(entity), metadataMigrationVersion))
+		if err == badger.ErrKeyNotFound {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		return item.Value(func(val []byte) error {
+			version = int(binary.BigEndian.Uint64(val))
+			return nil
+		})
+	})
+
+	return
+}
+
+// migrate runs a single migration
+func (db DB) migrate(m Migration) error {
+	root := KeyRoot(m.Entity)
+	prefix := typePrefix(contentKeyPrefix, root)
+	migratingKey := metadataKey(root, metadataMigrating)
+
+	// If this migration was interrupted, carry on after the last record migrated
+	seek := prefix
+	if err := db.KV.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(migratingKey)
+		if err == badger.ErrKeyNotFound {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		return item.Value(func(val []byte) error {
+			if int(binary.BigEndian.Uint64(val[:8])) == m.Version {
+				seek = append(append([]byte{}, val[8:]...), 0x00)
+			}
+
+			return nil
+		})
+	}); err != nil {
+		return err
+	}
+
+	if err := db.scanPrefixFrom(prefix, seek, true, func(chunk []rawItem) error {
+		return db.KV.Update(func(txn *badger.Txn) error {
+			for _, item := range chunk {
+				value, err := m.transform(db, item.value)
+				if err != nil {
+					return err
+				}
+
+				if err := setEntry(txn, item.key, value, item.expiresAt); err != nil {
+					return err
+				}
+			}
+
+			lastKey := chunk[len(chunk)-1].key
+			return txn.Set(migratingKey, append(versionBytes(m.Version), lastKey...))
+		})
+	}); err != nil {
+		return err
+	}
+
+	return db.KV.Update(func(txn *badger.Txn) error {
+		if err := txn.Delete(migratingKey); err != nil {
+			return err
+		}
+
+		return txn.Set(metadataKey(root, metadataMigrationVersion), versionBytes(m.Version))
+	})
+}
+
+func metadataKey(root []byte, name string) []byte {
+	return bytes.Join([][]byte{[]byte(metadataKeyPrefix), root, []byte(name)}, []byte(keySeparator))
+}
+
+func versionBytes(version int) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, uint64(version))
+	return b
+}
@@ -0,0 +1,138 @@
+package tormenta_test
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/jpincas/tormenta"
+	"github.com/jpincas/tormenta/testtypes"
+)
+
+func Test_Migrate(t *testing.T) {
+	db, _ := tormenta.OpenTestWithOptions("data/tests", testDBOptions)
+	defer db.Close()
+
+	entity := testtypes.MiniStruct{IntField: 1, StringField: "jon"}
+	db.Save(&entity)
+
+	if version, _ := db.MigrationVersion(&testtypes.MiniStruct{}); version != 0 {
+		t.Errorf("Testing migration version. Expected 0 before any migrations, got %v", version)
+	}
+
+	migrations := []tormenta.Migration{
+		// Given out of order on purpose
+		{
+			Entity:  &testtypes.MiniStruct{},
+			Version: 2,
+			Raw: func(value []byte) ([]byte, error) {
+				return bytes.Replace(value, []byte(`"JON"`), []byte(`"JONATHAN"`), 1), nil
+			},
+		},
+		{
+			Entity:  &testtypes.MiniStruct{},
+			Version: 1,
+			Map: func(record map[string]interface{}) error {
+				record["IntField"] = record["IntField"].(float64) * 10
+				record["StringField"] = strings.ToUpper(record["StringField"].(string))
+				return nil
+			},
+		},
+	}
+
+	if err := db.Migrate(migrations...); err != nil {
+		t.Fatalf("Testing migration. Got error %v", err)
+	}
+
+	// Running them again should have no effect
+	if err := db.Migrate(migrations...); err != nil {
+		t.Fatalf("Testing migration rerun. Got error %v", err)
+	}
+
+	result := testtypes.MiniStruct{}
+	db.Get(&result, entity.ID)
+
+	if result.IntField != 10 || result.StringField != "JONATHAN" {
+		t.Errorf("Testing migration. Expected the record to have been migrated once, got %v and %s", result.IntField, result.StringField)
+	}
+
+	if version, _ := db.MigrationVersion(&testtypes.MiniStruct{}); version != 2 {
+		t.Errorf("Testing migration version. Expected 2, got %v", version)
+	}
+
+	// Indexes should reflect the migrated records
+	if n, _ := db.Find(&[]testtypes.MiniStruct{}).Match("IntField", 10).Count(); n != 1 {
+		t.Errorf("Testing migration. Expected the migrated record to be reindexed, got %v results", n)
+	}
+
+	if n, _ := db.Find(&[]testtypes.MiniStruct{}).Match("IntField", 1).Count(); n != 0 {
+		t.Errorf("Testing migration. Expected the old index key to be gone, got %v results", n)
+	}
+}
+
+func Test_Migrate_Resume(t *testing.T) {
+	db, _ := tormenta.OpenTestWithOptions("data/tests", testDBOptions)
+	defer db.Close()
+
+	var entities []tormenta.Record
+	for i := 0; i < 1500; i++ {
+		entities = append(entities, &testtypes.MiniStruct{})
+	}
+
+	db.BulkLoad(entities...)
+
+	// Fail part way through the second chunk of records
+	counter := 0
+	increment := func(fail bool) tormenta.Migration {
+		return tormenta.Migration{
+			Entity:  &testtypes.MiniStruct{},
+			Version: 1,
+			Map: func(record map[string]interface{}) error {
+				counter++
+				if fail && counter == 1200 {
+					return errors.New("interrupted")
+				}
+
+				record["IntField"] = record["IntField"].(float64) + 1
+				return nil
+			},
+		}
+	}
+
+	if err := db.Migrate(increment(true)); err == nil {
+		t.Fatal("Testing migration resume. Expected the first run to fail")
+	}
+
+	if err := db.Migrate(increment(false)); err != nil {
+		t.Fatalf("Testing migration resume. Got error %v", err)
+	}
+
+	// Every record should have been migrated exactly once
+	if n, _ := db.Find(&[]testtypes.MiniStruct{}).Match("IntField", 1).Count(); n != 1500 {
+		t.Errorf("Testing migration resume. Expected 1500 records migrated once, got %v", n)
+	}
+}
+
+func Test_Migrate_Invalid(t *testing.T) {
+	db, _ := tormenta.OpenTestWithOptions("data/tests", testDBOptions)
+	defer db.Close()
+
+	noop := func(value []byte) ([]byte, error) { return value, nil }
+
+	testCases := []struct {
+		testName   string
+		migrations []tormenta.Migration
+	}{
+		{"no transform", []tormenta.Migration{{Entity: &testtypes.MiniStruct{}, Version: 1}}},
+		{"two transforms", []tormenta.Migration{{Entity: &testtypes.MiniStruct{}, Version: 1, Raw: noop, Map: func(map[string]interface{}) error { return nil }}}},
+		{"no version", []tormenta.Migration{{Entity: &testtypes.MiniStruct{}, Raw: noop}}},
+		{"duplicate version", []tormenta.Migration{{Entity: &testtypes.MiniStruct{}, Version: 1, Raw: noop}, {Entity: &testtypes.MiniStruct{}, Version: 1, Raw: noop}}},
+	}
+
+	for _, testCase := range testCases {
+		if err := db.Migrate(testCase.migrations...); err == nil {
+			t.Errorf("Testing invalid migrations (%s). Expected an error, got none", testCase.testName)
+		}
+	}
+}
@@ -6,10 +6,11 @@ import (
 	"github.com/jpincas/gouuidv6"
 )
 
+// Record is the interface all persisted types must satisfy,
+// which in practice they do by embedding Model.
+// Business logic can be hooked in by implementing any of the optional
+// interfaces PreSaver, PostSaver, PostGetter, Validator, PreDeleter and PostDeleter
 type Record interface {
-	PreSave(DB) ([]Record, error)
-	PostSave()
-	PostGet(ctx map[string]interface{})
 	GetCreated() time.Time
 	SetID(gouuidv6.UUID)
 	GetID() gouuidv6.UUID
@@ -19,6 +20,13 @@ type Model struct {
 	ID          gouuidv6.UUID `json:"id"`
 	Created     time.Time     `json:"created"`
 	LastUpdated time.Time     `json:"lastUpdated"`
+
+	// Version is incremented on every save and is used to detect
+	// concurrent modifications - see ErrVersionConflict
+	Version int64 `json:"version" tormenta:"noindex"`
+
+	// DeletedAt is set when a record is soft deleted - see SoftDelete
+	DeletedAt time.Time `json:"deletedAt" tormenta:"noindex"`
 }
 
 func newID() gouuidv6.UUID {
@@ -32,14 +40,6 @@ func newModel() Model {
 	}
 }
 
-func (m *Model) PreSave(db DB) ([]Record, error) {
-	return nil, nil
-}
-
-func (m *Model) PostSave() {}
-
-func (m *Model) PostGet(ctx map[string]interface{}) {}
-
 func (m *Model) SetID(id gouuidv6.UUID) {
 	m.ID = id
 }
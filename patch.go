@@ -0,0 +1,37 @@
+package tormenta
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/dgraph-io/badger"
+	"github.com/jpincas/gouuidv6"
+)
+
+// Patch sets only the given fields (by name) on the stored entity with the given ID,
+// leaving all the other fields as they are.  The stored entity is loaded, patched and saved
+// in a single transaction, so triggers run as for a normal save, LastUpdated and Version are bumped,
+// and only the index entries of the patched fields are rewritten.
+// Field names and value types are checked against the struct before anything is loaded.
+// On success, entity holds the patched entity.
+// If the DB is bound to an explicit transaction (see Update), that transaction is used
+func (db DB) Patch(entity Record, id gouuidv6.UUID, fields map[string]interface{}) error {
+	if err := setFields(newRecord(entity), fields); err != nil {
+		return err
+	}
+
+	return db.update(func(txn *badger.Txn) error {
+		if found, err := db.getLive(txn, entity, noCTX, id); err != nil {
+			return err
+		} else if !found {
+			return fmt.Errorf(ErrRecordNotFound, id)
+		}
+
+		if err := setFields(entity, fields); err != nil {
+			return err
+		}
+
+		_, err := db.save(context.Background(), txn, entity)
+		return err
+	})
+}
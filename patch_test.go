@@ -0,0 +1,91 @@
+package tormenta_test
+
+import (
+	"testing"
+
+	"github.com/jpincas/tormenta"
+	"github.com/jpincas/tormenta/testtypes"
+)
+
+func Test_Patch(t *testing.T) {
+	db, _ := tormenta.OpenTestWithOptions("data/tests", testDBOptions)
+	defer db.Close()
+
+	fullStruct := testtypes.FullStruct{IntField: 1, StringField: "keep me", FloatField: 1.5}
+	db.Save(&fullStruct)
+
+	patched := testtypes.FullStruct{}
+	if err := db.Patch(&patched, fullStruct.ID, map[string]interface{}{
+		"IntField": 2,
+	}); err != nil {
+		t.Fatalf("Testing patch. Got error %v", err)
+	}
+
+	retrieved := testtypes.FullStruct{}
+	db.Get(&retrieved, fullStruct.ID)
+
+	if retrieved.IntField != 2 {
+		t.Errorf("Testing patch. Expected IntField to be 2, got %v", retrieved.IntField)
+	}
+
+	if retrieved.StringField != "keep me" || retrieved.FloatField != 1.5 {
+		t.Errorf("Testing patch. Other fields should not have changed, got %+v", retrieved)
+	}
+
+	if retrieved.Version != 2 || !retrieved.LastUpdated.After(fullStruct.LastUpdated) {
+		t.Error("Testing patch. Version and LastUpdated should have been bumped")
+	}
+
+	if patched.ID != fullStruct.ID || patched.IntField != 2 || patched.Version != 2 {
+		t.Errorf("Testing patch. The entity passed in should hold the patched record, got %+v", patched)
+	}
+
+	testCases := []struct {
+		testName string
+		query    *tormenta.Query
+		expected int
+	}{
+		{"old value", db.Find(&[]testtypes.FullStruct{}).Match("IntField", 1), 0},
+		{"new value", db.Find(&[]testtypes.FullStruct{}).Match("IntField", 2), 1},
+		{"untouched field", db.Find(&[]testtypes.FullStruct{}).Match("StringField", "keep me"), 1},
+	}
+
+	for _, testCase := range testCases {
+		if c, _ := testCase.query.Count(); c != testCase.expected {
+			t.Errorf("Testing index after patch (%s). Expected %v results, got %v", testCase.testName, testCase.expected, c)
+		}
+	}
+}
+
+func Test_Patch_Errors(t *testing.T) {
+	db, _ := tormenta.OpenTestWithOptions("data/tests", testDBOptions)
+	defer db.Close()
+
+	fullStruct := testtypes.FullStruct{IntField: 1}
+	db.Save(&fullStruct)
+
+	testCases := []struct {
+		testName string
+		fields   map[string]interface{}
+	}{
+		{"non-existent field", map[string]interface{}{"NotAField": 1}},
+		{"wrong type", map[string]interface{}{"IntField": "two"}},
+		{"model field", map[string]interface{}{"LastUpdated": fullStruct.LastUpdated}},
+	}
+
+	for _, testCase := range testCases {
+		if err := db.Patch(&testtypes.FullStruct{}, fullStruct.ID, testCase.fields); err == nil {
+			t.Errorf("Testing patch (%s). Expected an error but did not get one", testCase.testName)
+		}
+	}
+
+	if err := db.Patch(&testtypes.FullStruct{}, testtypes.FullStruct{}.ID, map[string]interface{}{"IntField": 2}); err == nil {
+		t.Error("Testing patch of non-existent record. Expected an error but did not get one")
+	}
+
+	retrieved := testtypes.FullStruct{}
+	db.Get(&retrieved, fullStruct.ID)
+	if retrieved.Version != 1 || retrieved.IntField != 1 {
+		t.Errorf("Testing failed patches. Record should not have changed, got %+v", retrieved)
+	}
+}
@@ -1,6 +1,7 @@
 package tormenta
 
 import (
+	"context"
 	"fmt"
 	"time"
 
@@ -51,6 +52,13 @@ type Query struct {
 	// Pass-through context
 	ctx map[string]interface{}
 
+	// Standard library context, used for cancellation and deadlines.
+	// Not to be confused with the pass-through context above
+	goCtx context.Context
+
+	// Treatment of soft deleted records
+	deleted deletedMode
+
 	// Filter
 	filters    []filter
 	basicQuery *basicQuery
@@ -97,6 +105,15 @@ func (db DB) newQuery(target interface{}) *Query {
 }
 
 func (q *Query) addFilter(f filter) {
+	// A value that doesn't suit the index would otherwise only fail when the query is run,
+	// so check it now, where Err can report it
+	for _, value := range []interface{}{f.start, f.end} {
+		if _, err := f.valueBytes(value); err != nil {
+			q.err = err
+			return
+		}
+	}
+
 	q.filters = append(q.filters, f)
 }
 
@@ -119,6 +136,8 @@ func (q *Query) prepareQuery() {
 		q.filters[i].reverse = q.reverse
 		q.filters[i].from = q.from
 		q.filters[i].to = q.to
+		q.filters[i].goCtx = q.goCtx
+		q.filters[i].deleted = q.deleted
 
 		if q.shouldApplyLimitOffsetToFilter() {
 			q.filters[i].limit = q.limit
@@ -133,6 +152,8 @@ func (q *Query) prepareQuery() {
 			to:      q.to,
 			reverse: q.reverse,
 			keyRoot: q.keyRoot,
+			goCtx:   q.goCtx,
+			deleted: q.deleted,
 		}
 
 		if q.shouldApplyLimitOffsetToBasicQuery() {
@@ -173,7 +194,11 @@ func (q *Query) queryIDs(txn *badger.Txn) (idList, error) {
 		}
 	} else {
 		// FOR WHEN THERE ARE NO INDEX FILTERS
-		allResults = []idList{q.basicQuery.queryIDs(txn)}
+		ids, err := q.basicQuery.queryIDs(txn)
+		if err != nil {
+			return idList{}, err
+		}
+		allResults = []idList{ids}
 	}
 
 	// Combine the results from multiple filters,
@@ -182,25 +207,19 @@ func (q *Query) queryIDs(txn *badger.Txn) (idList, error) {
 	return q.idsCombinator(allResults...), nil
 }
 
-func (q *Query) execute() (int, error) {
-	// Start time for debugging, if required
-	t := time.Now()
-
-	txn := q.db.KV.NewTransaction(false)
-	defer txn.Discard()
-
+// resolveIDs works out the final list of IDs matched by the query,
+// ordered and with limit/offset applied
+func (q *Query) resolveIDs(txn *badger.Txn) (idList, error) {
 	finalIDList, err := q.queryIDs(txn)
 	if err != nil {
-		q.debugLog(t, 0, err)
-		return 0, err
+		return idList{}, err
 	}
 
 	// TODO: more conditions to restrict when this is necessary
 	if len(q.orderByIndexName) > 0 {
-		indexKind, err := fieldKind(q.target, string(q.orderByIndexName))
+		indexKind, err := q.fieldKind(string(q.orderByIndexName))
 		if err != nil {
-			q.debugLog(t, 0, err)
-			return 0, err
+			return idList{}, err
 		}
 
 		is := indexSearch{
@@ -211,6 +230,7 @@ func (q *Query) execute() (int, error) {
 			indexName:      q.orderByIndexName,
 			indexKind:      indexKind,
 			offset:         q.offset,
+			goCtx:          q.goCtx,
 		}
 
 		// If we are doing a quicksum and the sum index is the same
@@ -224,7 +244,43 @@ func (q *Query) execute() (int, error) {
 		}
 
 		// This will order and apply limit/offset
-		finalIDList = is.execute(txn)
+		return is.execute(txn)
+	}
+
+	return finalIDList, nil
+}
+
+// operation describes the query for the middleware
+func (q *Query) operation() Operation {
+	return Operation{
+		Type:    QueryOperation,
+		KeyRoot: string(q.keyRoot),
+		Target:  q.target,
+		Query:   q,
+		Ctx:     q.ctx,
+		Context: q.goCtx,
+	}
+}
+
+func (q *Query) execute() (n int, err error) {
+	err = q.db.run(q.operation(), func(Operation) error {
+		return q.db.view(func(txn *badger.Txn) (err error) {
+			n, err = q.executeWithTxn(txn)
+			return
+		})
+	})
+
+	return
+}
+
+func (q *Query) executeWithTxn(txn *badger.Txn) (int, error) {
+	// Start time for debugging, if required
+	t := time.Now()
+
+	finalIDList, err := q.resolveIDs(txn)
+	if err != nil {
+		q.debugLog(t, 0, err)
+		return 0, err
 	}
 
 	// For count-only, there's nothing more to do
@@ -241,7 +297,7 @@ func (q *Query) execute() (int, error) {
 	if len(q.sumIndexName) > 0 && q.sumTarget != nil {
 		if string(q.sumIndexName) != string(q.orderByIndexName) {
 
-			indexKind, err := fieldKind(q.target, string(q.sumIndexName))
+			indexKind, err := q.fieldKind(string(q.sumIndexName))
 			if err != nil {
 				q.debugLog(t, 0, err)
 				return 0, err
@@ -257,9 +313,13 @@ func (q *Query) execute() (int, error) {
 				offset:         q.offset,
 				sumIndexName:   q.sumIndexName,
 				sumTarget:      q.sumTarget,
+				goCtx:          q.goCtx,
 			}
 
-			is.execute(txn)
+			if _, err := is.execute(txn); err != nil {
+				q.debugLog(t, 0, err)
+				return 0, err
+			}
 		}
 
 		// Now, whether the quicksum was on the same index as order,
@@ -268,6 +328,11 @@ func (q *Query) execute() (int, error) {
 		return len(finalIDList), nil
 	}
 
+	if !q.requireTarget() {
+		q.debugLog(t, 0, q.err)
+		return 0, q.err
+	}
+
 	// For 'First' type queries
 	if q.single {
 		// For 'first' queries, we should check that there is at least 1 record found
@@ -279,6 +344,11 @@ func (q *Query) execute() (int, error) {
 
 		// db.get ususally takes a 'Record', so we need to set a new one up
 		// and then set the result of get to the target aftwards
+		if err := ctxErr(q.goCtx); err != nil {
+			q.debugLog(t, 0, err)
+			return 0, err
+		}
+
 		record := newRecord(q.target)
 		id := finalIDList[0]
 		if found, err := q.db.get(txn, record, q.ctx, id); err != nil {
@@ -296,7 +366,7 @@ func (q *Query) execute() (int, error) {
 	}
 
 	// Otherwise we just get the records and return
-	n, err := q.db.getIDsWithContext(txn, q.target, q.ctx, finalIDList...)
+	n, err := q.db.getIDsWithContext(q.goCtx, txn, q.target, q.ctx, finalIDList...)
 	if err != nil {
 		q.debugLog(t, 0, err)
 		return 0, err
@@ -0,0 +1,96 @@
+package tormenta_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jpincas/tormenta"
+	"github.com/jpincas/tormenta/testtypes"
+)
+
+func Test_QueryDelete(t *testing.T) {
+	noRecords := 2500
+
+	testCases := []struct {
+		testName        string
+		query           func(db *tormenta.DB) *tormenta.Query
+		expectedDeleted int
+	}{
+		{"all", func(db *tormenta.DB) *tormenta.Query {
+			return db.Find(&[]testtypes.FullStruct{})
+		}, noRecords},
+		{"limit", func(db *tormenta.DB) *tormenta.Query {
+			return db.Find(&[]testtypes.FullStruct{}).Limit(10)
+		}, 10},
+		{"index match", func(db *tormenta.DB) *tormenta.Query {
+			return db.Find(&[]testtypes.FullStruct{}).Match("IntField", 1)
+		}, noRecords / 10},
+		{"index match with limit", func(db *tormenta.DB) *tormenta.Query {
+			return db.Find(&[]testtypes.FullStruct{}).Match("IntField", 1).Limit(5)
+		}, 5},
+		{"and", func(db *tormenta.DB) *tormenta.Query {
+			return db.Find(&[]testtypes.FullStruct{}).Range("IntField", 1, 5).Match("StringField", "odd")
+		}, 3 * noRecords / 10},
+		{"or", func(db *tormenta.DB) *tormenta.Query {
+			return db.Find(&[]testtypes.FullStruct{}).Match("IntField", 1).Or().Match("IntField", 2)
+		}, 2 * noRecords / 10},
+		{"date range", func(db *tormenta.DB) *tormenta.Query {
+			return db.Find(&[]testtypes.FullStruct{}).From(time.Now().Add(-time.Hour)).To(time.Now().Add(time.Hour))
+		}, noRecords},
+		{"date range excluding everything", func(db *tormenta.DB) *tormenta.Query {
+			return db.Find(&[]testtypes.FullStruct{}).To(time.Now().Add(-time.Hour))
+		}, 0},
+		{"order by with limit", func(db *tormenta.DB) *tormenta.Query {
+			return db.Find(&[]testtypes.FullStruct{}).OrderBy("IntField").Limit(20)
+		}, 20},
+	}
+
+	for _, testCase := range testCases {
+		db, _ := tormenta.OpenTestWithOptions("data/tests", testDBOptions)
+
+		var toSave []tormenta.Record
+		for i := 0; i < noRecords; i++ {
+			stringField := "even"
+			if i%2 == 1 {
+				stringField = "odd"
+			}
+
+			toSave = append(toSave, &testtypes.FullStruct{IntField: i % 10, StringField: stringField})
+		}
+
+		if _, err := db.BulkLoad(toSave...); err != nil {
+			t.Fatalf("Testing query delete (%s). Could not load records: %v", testCase.testName, err)
+		}
+
+		n, err := testCase.query(db).Delete()
+		if err != nil {
+			t.Errorf("Testing query delete (%s). Got error %v", testCase.testName, err)
+		}
+
+		if n != testCase.expectedDeleted {
+			t.Errorf("Testing query delete (%s). Expected %v deleted, got %v", testCase.testName, testCase.expectedDeleted, n)
+		}
+
+		// The deleted records should be gone from the main query and from the indexes
+		var results []testtypes.FullStruct
+		if c, _ := db.Find(&results).Count(); c != noRecords-testCase.expectedDeleted {
+			t.Errorf("Testing query delete (%s). Expected %v remaining, got %v", testCase.testName, noRecords-testCase.expectedDeleted, c)
+		}
+
+		if c, _ := testCase.query(db).Count(); testCase.expectedDeleted == noRecords && c != 0 {
+			t.Errorf("Testing query delete (%s). Expected the query to match nothing afterwards, got %v", testCase.testName, c)
+		}
+
+		var indexCount int
+		for i := 0; i < 10; i++ {
+			c, _ := db.Find(&results).Match("IntField", i).Count()
+			indexCount += c
+		}
+
+		if indexCount != noRecords-testCase.expectedDeleted {
+			t.Errorf("Testing query delete (%s). Expected %v records left in the index, got %v", testCase.testName, noRecords-testCase.expectedDeleted, indexCount)
+		}
+
+		db.Close()
+	}
+}
@@ -0,0 +1,174 @@
+package tormenta_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/jpincas/tormenta"
+	"github.com/jpincas/tormenta/testtypes"
+)
+
+func Test_IndexQuery_MatchComposite(t *testing.T) {
+	db, _ := tormenta.OpenTestWithOptions("data/tests", testDBOptions)
+	defer db.Close()
+
+	db.Save(
+		&testtypes.CompositeStruct{Customer: "jon", Status: "paid", Amount: 30},
+		&testtypes.CompositeStruct{Customer: "jon", Status: "paid", Amount: 10},
+		&testtypes.CompositeStruct{Customer: "jon", Status: "paid", Amount: 20},
+		&testtypes.CompositeStruct{Customer: "jon", Status: "unpaid", Amount: 5},
+		&testtypes.CompositeStruct{Customer: "jonathan", Status: "paid", Amount: 15},
+		&testtypes.CompositeStruct{Customer: "jo", Status: "paidup", Amount: 15},
+	)
+
+	testCases := []struct {
+		testName        string
+		indexName       string
+		values          []interface{}
+		reverse         bool
+		expectedAmounts []int
+	}{
+		{"full match", "CustomerStatus", []interface{}{"jon", "paid"}, false, []int{30, 10, 20}},
+		{"full match - case insensitive", "CustomerStatus", []interface{}{"JON", "Paid"}, false, []int{30, 10, 20}},
+		{"full match - no interference", "CustomerStatus", []interface{}{"jo", "paid"}, false, nil},
+		{"full match with all fields", "CustomerStatusAmount", []interface{}{"jon", "paid", 20}, false, []int{20}},
+		{"partial match - ordered by remaining fields", "CustomerStatusAmount", []interface{}{"jon", "paid"}, false, []int{10, 20, 30}},
+		{"partial match - reversed", "CustomerStatusAmount", []interface{}{"jon", "paid"}, true, []int{30, 20, 10}},
+		{"partial match - first field only", "CustomerStatusAmount", []interface{}{"jon"}, false, []int{10, 20, 30, 5}},
+		{"partial match - no interference", "CustomerStatus", []interface{}{"jo"}, false, []int{15}},
+	}
+
+	for _, testCase := range testCases {
+		results := []testtypes.CompositeStruct{}
+
+		q := db.Find(&results).MatchComposite(testCase.indexName, testCase.values...)
+		if testCase.reverse {
+			q.Reverse()
+		}
+
+		if _, err := q.Run(); err != nil {
+			t.Errorf("Testing %s. Got error %v", testCase.testName, err)
+			continue
+		}
+
+		var amounts []int
+		for _, result := range results {
+			amounts = append(amounts, result.Amount)
+		}
+
+		if fmt.Sprint(amounts) != fmt.Sprint(testCase.expectedAmounts) {
+			t.Errorf("Testing %s. Expected amounts %v, got %v", testCase.testName, testCase.expectedAmounts, amounts)
+		}
+	}
+}
+
+func Test_IndexQuery_RangeComposite(t *testing.T) {
+	db, _ := tormenta.OpenTestWithOptions("data/tests", testDBOptions)
+	defer db.Close()
+
+	for i := 1; i <= 10; i++ {
+		db.Save(&testtypes.CompositeStruct{Customer: "jon", Status: "paid", Amount: i * 10})
+	}
+
+	db.Save(
+		&testtypes.CompositeStruct{Customer: "jon", Status: "unpaid", Amount: 50},
+		&testtypes.CompositeStruct{Customer: "pablo", Status: "paid", Amount: 50},
+	)
+
+	testCases := []struct {
+		testName  string
+		start     []interface{}
+		end       []interface{}
+		reverse   bool
+		expectedN int
+	}{
+		{"closed range", []interface{}{"jon", "paid", 20}, []interface{}{"jon", "paid", 50}, false, 4},
+		{"closed range - reversed", []interface{}{"jon", "paid", 20}, []interface{}{"jon", "paid", 50}, true, 4},
+		{"partial end", []interface{}{"jon", "paid", 20}, []interface{}{"jon", "paid"}, false, 9},
+		{"partial end - reversed", []interface{}{"jon", "paid", 20}, []interface{}{"jon", "paid"}, true, 9},
+		{"partial start and end", []interface{}{"jon"}, []interface{}{"jon"}, false, 11},
+		{"open start", nil, []interface{}{"jon", "paid", 30}, false, 3},
+		{"open end", []interface{}{"jon", "unpaid"}, nil, false, 2},
+	}
+
+	for _, testCase := range testCases {
+		results := []testtypes.CompositeStruct{}
+
+		q := db.Find(&results).RangeComposite("CustomerStatusAmount", testCase.start, testCase.end)
+		if testCase.reverse {
+			q.Reverse()
+		}
+
+		n, err := q.Run()
+		if err != nil {
+			t.Errorf("Testing %s. Got error %v", testCase.testName, err)
+			continue
+		}
+
+		if n != testCase.expectedN {
+			t.Errorf("Testing %s. Expected %v results, got %v", testCase.testName, testCase.expectedN, n)
+		}
+	}
+}
+
+func Test_IndexQuery_Composite_Indexer(t *testing.T) {
+	db, _ := tormenta.OpenTestWithOptions("data/tests", testDBOptions)
+	defer db.Close()
+
+	now := time.Now()
+	entity := testtypes.CompositeIndexerStruct{Customer: "jon", OrderDate: now}
+	db.Save(
+		&entity,
+		&testtypes.CompositeIndexerStruct{Customer: "jon", OrderDate: now.Add(-48 * time.Hour)},
+	)
+
+	results := []testtypes.CompositeIndexerStruct{}
+	n, err := db.Find(&results).RangeComposite("CustomerOrderDate", []interface{}{"jon", now.Add(-24 * time.Hour)}, []interface{}{"jon"}).Run()
+	if err != nil {
+		t.Fatalf("Testing composite index declared by method. Got error %v", err)
+	}
+
+	if n != 1 || results[0].ID != entity.ID {
+		t.Errorf("Testing composite index declared by method. Expected to find only the recent entity, got %v results", n)
+	}
+
+	// Updating a field moves the entity in the index
+	entity.Customer = "pablo"
+	db.Save(&entity)
+
+	if n, _ := db.Find(&results).MatchComposite("CustomerOrderDate", "jon").Count(); n != 1 {
+		t.Errorf("Testing composite index reindexing. Expected 1 result for the old value, got %v", n)
+	}
+
+	if n, _ := db.Find(&results).MatchComposite("CustomerOrderDate", "pablo").Count(); n != 1 {
+		t.Errorf("Testing composite index reindexing. Expected 1 result for the new value, got %v", n)
+	}
+}
+
+func Test_IndexQuery_Composite_Errors(t *testing.T) {
+	db, _ := tormenta.OpenTestWithOptions("data/tests", testDBOptions)
+	defer db.Close()
+
+	testCases := []struct {
+		testName string
+		query    *tormenta.Query
+	}{
+		{"no such index", db.Find(&[]testtypes.CompositeStruct{}).MatchComposite("Nothing", "jon")},
+		{"no values", db.Find(&[]testtypes.CompositeStruct{}).MatchComposite("CustomerStatus")},
+		{"too many values", db.Find(&[]testtypes.CompositeStruct{}).MatchComposite("CustomerStatus", "jon", "paid", 10)},
+		{"no range", db.Find(&[]testtypes.CompositeStruct{}).RangeComposite("CustomerStatus", nil, nil)},
+		{"name clashes with a field", db.Find(&[]testtypes.CompositeNameClashStruct{}).MatchComposite("Status", "jon")},
+	}
+
+	for _, testCase := range testCases {
+		if _, err := testCase.query.Run(); err == nil {
+			t.Errorf("Testing composite index errors (%s). Expected an error, got none", testCase.testName)
+		}
+	}
+
+	// Saving is refused too, as the keys would get mixed up with those of the field
+	if _, err := db.Save(&testtypes.CompositeNameClashStruct{Customer: "jon", Status: "paid"}); err == nil {
+		t.Error("Testing composite index with the same name as a field. Expected an error saving, got none")
+	}
+}
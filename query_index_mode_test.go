@@ -0,0 +1,58 @@
+package tormenta_test
+
+import (
+	"testing"
+
+	"github.com/jpincas/tormenta"
+	"github.com/jpincas/tormenta/testtypes"
+)
+
+func Test_IndexQuery_IndexModes(t *testing.T) {
+	db, _ := tormenta.OpenTestWithOptions("data/tests", testDBOptions)
+	defer db.Close()
+
+	db.Save(
+		&testtypes.IndexModeStruct{Name: "Jon", SKU: "AbC-1", City: "Zürich", Codes: []string{"Xy", "xy"}},
+		&testtypes.IndexModeStruct{Name: "jon", SKU: "abc-1", City: "Crème", Codes: []string{"XY"}},
+		&testtypes.IndexModeStruct{Name: "JON", SKU: "ABC-2", City: "Straße", Codes: []string{"ab"}},
+	)
+
+	testCases := []struct {
+		testName string
+		query    func(*tormenta.Query) *tormenta.Query
+		expected int
+	}{
+		// Default mode - case insensitive
+		{"default match", func(q *tormenta.Query) *tormenta.Query { return q.Match("Name", "jOn") }, 3},
+
+		// Exact mode - case sensitive
+		{"exact match", func(q *tormenta.Query) *tormenta.Query { return q.Match("SKU", "AbC-1") }, 1},
+		{"exact match - lower case", func(q *tormenta.Query) *tormenta.Query { return q.Match("SKU", "abc-1") }, 1},
+		{"exact match - no match", func(q *tormenta.Query) *tormenta.Query { return q.Match("SKU", "ABC-1") }, 0},
+		{"exact starts with", func(q *tormenta.Query) *tormenta.Query { return q.StartsWith("SKU", "ABC") }, 1},
+		{"exact starts with - lower case", func(q *tormenta.Query) *tormenta.Query { return q.StartsWith("SKU", "abc") }, 1},
+		{"exact range", func(q *tormenta.Query) *tormenta.Query { return q.Range("SKU", "A", "Z") }, 2},
+		{"exact slice", func(q *tormenta.Query) *tormenta.Query { return q.Match("Codes", "XY") }, 1},
+		{"exact slice - mixed case", func(q *tormenta.Query) *tormenta.Query { return q.Match("Codes", "Xy") }, 1},
+
+		// Fold mode - case insensitive and accents stripped
+		{"fold match", func(q *tormenta.Query) *tormenta.Query { return q.Match("City", "zurich") }, 1},
+		{"fold match - accents", func(q *tormenta.Query) *tormenta.Query { return q.Match("City", "ZÜRICH") }, 1},
+		{"fold match - case folding", func(q *tormenta.Query) *tormenta.Query { return q.Match("City", "STRASSE") }, 1},
+		{"fold starts with", func(q *tormenta.Query) *tormenta.Query { return q.StartsWith("City", "CREM") }, 1},
+		{"fold range", func(q *tormenta.Query) *tormenta.Query { return q.Range("City", "crema", "zz") }, 3},
+	}
+
+	for _, testCase := range testCases {
+		results := []testtypes.IndexModeStruct{}
+		n, err := testCase.query(db.Find(&results)).Run()
+		if err != nil {
+			t.Errorf("Testing index modes (%s). Got error %v", testCase.testName, err)
+			continue
+		}
+
+		if n != testCase.expected {
+			t.Errorf("Testing index modes (%s). Expected %v results, got %v", testCase.testName, testCase.expected, n)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package tormenta_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/jpincas/tormenta"
+	"github.com/jpincas/tormenta/testtypes"
+)
+
+func Test_QueryUpdate(t *testing.T) {
+	db, _ := tormenta.OpenTestWithOptions("data/tests", testDBOptions)
+	defer db.Close()
+
+	noRecords := 2500
+
+	var toSave []tormenta.Record
+	for i := 0; i < noRecords; i++ {
+		toSave = append(toSave, &testtypes.FullStruct{IntField: i % 10, StringField: "active"})
+	}
+	db.BulkLoad(toSave...)
+
+	// JSON style numbers should be converted to the field type
+	n, err := db.Find(&[]testtypes.FullStruct{}).Match("IntField", 1).Update(map[string]interface{}{
+		"StringField": "archived",
+		"IntField":    float64(100),
+	})
+
+	if err != nil {
+		t.Fatalf("Testing query update. Got error %v", err)
+	}
+
+	if n != noRecords/10 {
+		t.Errorf("Testing query update. Expected %v updated, got %v", noRecords/10, n)
+	}
+
+	testCases := []struct {
+		testName string
+		query    *tormenta.Query
+		expected int
+	}{
+		{"old int index", db.Find(&[]testtypes.FullStruct{}).Match("IntField", 1), 0},
+		{"new int index", db.Find(&[]testtypes.FullStruct{}).Match("IntField", 100), noRecords / 10},
+		{"old string index", db.Find(&[]testtypes.FullStruct{}).Match("StringField", "active"), noRecords - noRecords/10},
+		{"new string index", db.Find(&[]testtypes.FullStruct{}).Match("StringField", "archived"), noRecords / 10},
+		{"unchanged index", db.Find(&[]testtypes.FullStruct{}).Match("IntField", 2), noRecords / 10},
+		{"all", db.Find(&[]testtypes.FullStruct{}), noRecords},
+	}
+
+	for _, testCase := range testCases {
+		if c, _ := testCase.query.Count(); c != testCase.expected {
+			t.Errorf("Testing query update (%s). Expected %v results, got %v", testCase.testName, testCase.expected, c)
+		}
+	}
+
+	// Triggers should have run and the version should have been bumped
+	var results []testtypes.FullStruct
+	db.Find(&results).Match("IntField", 100).Limit(1).Run()
+	if len(results) != 1 {
+		t.Fatal("Testing query update. Could not retrieve an updated record")
+	}
+
+	if results[0].TriggerString != "triggered" {
+		t.Error("Testing query update. Presave trigger did not run")
+	}
+
+	if results[0].Version != 2 {
+		t.Errorf("Testing query update. Expected version 2, got %v", results[0].Version)
+	}
+}
+
+func Test_QueryUpdateFunc(t *testing.T) {
+	db, _ := tormenta.OpenTestWithOptions("data/tests", testDBOptions)
+	defer db.Close()
+
+	for i := 0; i < 10; i++ {
+		db.Save(&testtypes.MiniStruct{IntField: i})
+	}
+
+	n, err := db.Find(&[]testtypes.MiniStruct{}).Range("IntField", 0, 4).UpdateFunc(func(record tormenta.Record) error {
+		record.(*testtypes.MiniStruct).IntField += 100
+		return nil
+	})
+
+	if err != nil || n != 5 {
+		t.Errorf("Testing query update func. Expected 5 updated and no error, got %v / %v", n, err)
+	}
+
+	if c, _ := db.Find(&[]testtypes.MiniStruct{}).Range("IntField", 100, 104).Count(); c != 5 {
+		t.Errorf("Testing query update func. Expected 5 results in the new range, got %v", c)
+	}
+
+	// An error from the function aborts the update
+	updateErr := errors.New("stop")
+	if _, err := db.Find(&[]testtypes.MiniStruct{}).UpdateFunc(func(record tormenta.Record) error {
+		record.(*testtypes.MiniStruct).IntField = -1
+		return updateErr
+	}); err != updateErr {
+		t.Errorf("Testing query update func with failing function. Expected the function's error, got %v", err)
+	}
+
+	if c, _ := db.Find(&[]testtypes.MiniStruct{}).Match("IntField", -1).Count(); c != 0 {
+		t.Errorf("Testing query update func with failing function. Expected no records to have changed, got %v", c)
+	}
+}
+
+func Test_QueryUpdate_BadFields(t *testing.T) {
+	db, _ := tormenta.OpenTestWithOptions("data/tests", testDBOptions)
+	defer db.Close()
+
+	db.Save(&testtypes.FullStruct{IntField: 1})
+
+	testCases := []struct {
+		testName string
+		fields   map[string]interface{}
+	}{
+		{"non-existent field", map[string]interface{}{"NotAField": 1}},
+		{"wrong type", map[string]interface{}{"IntField": "one"}},
+		{"lossy conversion", map[string]interface{}{"IntField": 1.5}},
+		{"negative into unsigned", map[string]interface{}{"UintField": -1}},
+		{"model field", map[string]interface{}{"ID": testtypes.FullStruct{}.ID}},
+	}
+
+	for _, testCase := range testCases {
+		if _, err := db.Find(&[]testtypes.FullStruct{}).Update(testCase.fields); err == nil {
+			t.Errorf("Testing query update with bad fields (%s). Expected an error but did not get one", testCase.testName)
+		}
+	}
+
+	retrieved := []testtypes.FullStruct{}
+	db.Find(&retrieved).Run()
+	if retrieved[0].Version != 1 {
+		t.Errorf("Testing query update with bad fields. Record should not have been saved, but has version %v", retrieved[0].Version)
+	}
+}
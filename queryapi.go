@@ -1,6 +1,7 @@
 package tormenta
 
 import (
+	"context"
 	"errors"
 	"strings"
 	"time"
@@ -29,6 +30,13 @@ func (q *Query) Debug() *Query {
 	return q
 }
 
+// Err returns the error, if any, from building the query so far,
+// e.g. an index search on a field that doesn't exist.
+// The same error is returned when the query is run
+func (q *Query) Err() error {
+	return q.err
+}
+
 // CONTEXT SETTING
 
 // SetContext allows a context to be passed through the query
@@ -48,13 +56,17 @@ func (q *Query) Match(indexName string, param interface{}) *Query {
 		return q
 	}
 
-	// If we are matching a string, lower-case it
+	indexMode := targetIndexMode(q.target, indexName)
+
+	// If we are matching a string on a regular index, lower-case it
 	switch param.(type) {
 	case string:
-		param = strings.ToLower(param.(string))
+		if indexMode == indexModeLower {
+			param = strings.ToLower(param.(string))
+		}
 	}
 
-	indexKind, err := fieldKind(q.target, indexName)
+	indexKind, err := q.fieldKind(indexName, param)
 	if err != nil {
 		q.err = err
 		return q
@@ -66,6 +78,7 @@ func (q *Query) Match(indexName string, param interface{}) *Query {
 		end:       param,
 		indexName: toIndexName(indexName),
 		indexKind: indexKind,
+		indexMode: indexMode,
 	})
 
 	return q
@@ -81,7 +94,7 @@ func (q *Query) Range(indexName string, start, end interface{}) *Query {
 		return q
 	}
 
-	indexKind, err := fieldKind(q.target, indexName)
+	indexKind, err := q.fieldKind(indexName, start, end)
 	if err != nil {
 		q.err = err
 		return q
@@ -93,6 +106,7 @@ func (q *Query) Range(indexName string, start, end interface{}) *Query {
 		end:       end,
 		indexName: toIndexName(indexName),
 		indexKind: indexKind,
+		indexMode: targetIndexMode(q.target, indexName),
 	})
 
 	return q
@@ -106,7 +120,7 @@ func (q *Query) StartsWith(indexName string, s string) *Query {
 		return q
 	}
 
-	indexKind, err := fieldKind(q.target, indexName)
+	indexKind, err := q.fieldKind(indexName, s)
 	if err != nil {
 		q.err = err
 		return q
@@ -119,11 +133,100 @@ func (q *Query) StartsWith(indexName string, s string) *Query {
 		isStartsWithQuery: true,
 		indexName:         toIndexName(indexName),
 		indexKind:         indexKind,
+		indexMode:         targetIndexMode(q.target, indexName),
 	})
 
 	return q
 }
 
+// MatchComposite adds a search on a composite index.  Values are matched against
+// the fields of the index in order.  Giving fewer values than there are fields
+// matches on the leading fields only, and the results are then ordered by the remaining fields,
+// e.g. MatchComposite("CustomerStatusAmount", customerID, "paid") returns the customer's paid orders by amount
+func (q *Query) MatchComposite(indexName string, values ...interface{}) *Query {
+	if len(values) == 0 {
+		q.err = errors.New(ErrNilInputMatchIndexQuery)
+		return q
+	}
+
+	if !q.requireTarget() {
+		return q
+	}
+
+	entity := q.newTargetRecord()
+
+	index, err := findCompositeIndex(entity, indexName)
+	if err != nil {
+		q.err = err
+		return q
+	}
+
+	encoded, err := encodeCompositeValues(entity, index, values)
+	if err != nil {
+		q.err = err
+		return q
+	}
+
+	if len(values) == len(index.Fields) {
+		q.compositeFilter(indexName, encoded, encoded)
+		return q
+	}
+
+	// A partial match is a range covering everything starting with the leading values.
+	// The separator makes sure that e.g. "Jon" doesn't match "Jonathan"
+	start := append(append([]byte{}, encoded...), compositeValueSeparator...)
+	end := append(encoded, compositeValueUpperBound...)
+	q.compositeFilter(indexName, start, end)
+	return q
+}
+
+// RangeComposite adds a range search on a composite index, between two sets of values (inclusive).
+// Either end can be left open with nil, and either can give fewer values than there are fields,
+// e.g. RangeComposite("CustomerStatusAmount", []interface{}{customerID, "paid", 100}, []interface{}{customerID, "paid"})
+// returns the customer's paid orders of 100 or more
+func (q *Query) RangeComposite(indexName string, start, end []interface{}) *Query {
+	if len(start) == 0 && len(end) == 0 {
+		q.err = errors.New(ErrNilInputsRangeIndexQuery)
+		return q
+	}
+
+	if !q.requireTarget() {
+		return q
+	}
+
+	entity := q.newTargetRecord()
+
+	index, err := findCompositeIndex(entity, indexName)
+	if err != nil {
+		q.err = err
+		return q
+	}
+
+	var startBytes, endBytes []byte
+
+	if len(start) > 0 {
+		if startBytes, err = encodeCompositeValues(entity, index, start); err != nil {
+			q.err = err
+			return q
+		}
+	}
+
+	if len(end) > 0 {
+		if endBytes, err = encodeCompositeValues(entity, index, end); err != nil {
+			q.err = err
+			return q
+		}
+
+		// A partial end includes everything starting with its values
+		if len(end) < len(index.Fields) {
+			endBytes = append(endBytes, compositeValueUpperBound...)
+		}
+	}
+
+	q.compositeFilter(indexName, startBytes, endBytes)
+	return q
+}
+
 // GLOBAL QUERY MODIFIERS
 
 // Sets the query to return filter results combined in a logical OR way instead of AND.
@@ -143,6 +246,18 @@ func (q *Query) And() *Query {
 	return q
 }
 
+// WithDeleted includes soft deleted records in the results of the query
+func (q *Query) WithDeleted() *Query {
+	q.deleted = includeDeleted
+	return q
+}
+
+// OnlyDeleted restricts the results of the query to soft deleted records
+func (q *Query) OnlyDeleted() *Query {
+	q.deleted = onlyDeleted
+	return q
+}
+
 // Limit limits the number of results a Query will return to n.
 // If a limit has already been set on a query and you try to set a new one, it will only
 // be overriden if it is lower.  This allows you easily set a 'hard' limit up front,
@@ -178,6 +293,12 @@ func (q *Query) UnReverse() *Query {
 
 // OrderBy specifies an index by which to order results..
 func (q *Query) OrderBy(indexName string) *Query {
+	// Check the field is there now, rather than when the query is run
+	if _, err := q.fieldKind(indexName); err != nil {
+		q.err = err
+		return q
+	}
+
 	q.orderByIndexName = toIndexName(indexName)
 	return q
 }
@@ -222,3 +343,55 @@ func (q *Query) Sum(a interface{}, indexName string) (int, error) {
 	q.sumIndexName = toIndexName(indexName)
 	return q.execute()
 }
+
+// Delete deletes every entity matched by the Query, returning the number of entities deleted.
+// Limit, offset, date ranges and filters are all respected.
+// Deletes are carried out in chunks, each in its own transaction,
+// so a failure part way through leaves the earlier chunks deleted.
+func (q *Query) Delete() (int, error) {
+	return q.writeIDs(q.deleteIDs)
+}
+
+// UpdateFunc applies fn to every entity matched by the Query and saves the result,
+// returning the number of entities updated.  PreSave and PostSave triggers run as normal
+// and only the index entries affected by the change are rewritten.
+// An error from fn aborts the update.  Updates are carried out in chunks,
+// each in its own transaction, so a failure part way through leaves the earlier chunks updated.
+func (q *Query) UpdateFunc(fn func(Record) error) (int, error) {
+	return q.writeIDs(q.updateIDs(fn))
+}
+
+// Update sets the given fields (by name) to the given values on every entity matched by the Query.
+// See UpdateFunc for the details.
+func (q *Query) Update(fields map[string]interface{}) (int, error) {
+	// Check the fields and values up front on a blank record,
+	// rather than finding a problem part way through
+	if err := setFields(q.newTargetRecord(), fields); err != nil {
+		return 0, err
+	}
+
+	return q.UpdateFunc(func(record Record) error {
+		return setFields(record, fields)
+	})
+}
+
+// RunCtx executes the Query, abandoning it and returning ctx.Err()
+// if the context is cancelled or its deadline passes
+func (q *Query) RunCtx(ctx context.Context) (int, error) {
+	q.goCtx = ctx
+	return q.Run()
+}
+
+// CountCtx executes the Query in fast, count-only mode, abandoning it and returning ctx.Err()
+// if the context is cancelled or its deadline passes
+func (q *Query) CountCtx(ctx context.Context) (int, error) {
+	q.goCtx = ctx
+	return q.Count()
+}
+
+// SumCtx produces an index-only sum aggregation, abandoning it and returning ctx.Err()
+// if the context is cancelled or its deadline passes
+func (q *Query) SumCtx(ctx context.Context, a interface{}, indexName string) (int, error) {
+	q.goCtx = ctx
+	return q.Sum(a, indexName)
+}
@@ -31,6 +31,7 @@ const (
 	queryStringStart      = "start"
 	queryStringEnd        = "end"
 	queryStringIndex      = "index"
+	queryStringDeleted    = "deleted"
 
 	// Error messages
 	ErrBadFormatQueryValue            = "Bad format for query value"
@@ -303,6 +304,10 @@ func (q Query) String() string {
 		components = append(components, queryComponent{queryStringReverse, q.reverse})
 	}
 
+	if q.deleted != excludeDeleted {
+		components = append(components, queryComponent{queryStringDeleted, q.deleted})
+	}
+
 	if isOr := isOr(q.idsCombinator); isOr {
 		components = append(components, queryComponent{queryStringOr, isOr})
 	}
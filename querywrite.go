@@ -0,0 +1,133 @@
+package tormenta
+
+import (
+	"time"
+
+	"github.com/dgraph-io/badger"
+)
+
+// Bulk writes driven by a query are split into transactions of this many records,
+// to keep well clear of Badger's maximum transaction size
+const queryWriteChunkSize = 1000
+
+// newTargetRecord creates a new, blank record of the type the query is searching for
+func (q *Query) newTargetRecord() Record {
+	if q.single {
+		return newRecord(q.target)
+	}
+
+	return newRecordFromSlice(q.target)
+}
+
+// writeIDs resolves the IDs matched by the query, which passes through the middleware
+// like any other query, then runs fn over them,
+// one chunk of IDs per update transaction.  If the DB is bound to an explicit transaction,
+// all the chunks are run in that transaction.
+// Note that, unlike Save, the whole operation is not atomic:
+// if a chunk fails, the chunks before it will already have been committed.
+func (q *Query) writeIDs(fn func(*badger.Txn, idList) (int, error)) (int, error) {
+	// Start time for debugging, if required
+	t := time.Now()
+
+	if !q.requireTarget() {
+		q.debugLog(t, 0, q.err)
+		return 0, q.err
+	}
+
+	var ids idList
+	if err := q.db.run(q.operation(), func(Operation) error {
+		return q.db.view(func(txn *badger.Txn) (err error) {
+			ids, err = q.resolveIDs(txn)
+			return
+		})
+	}); err != nil {
+		q.debugLog(t, 0, err)
+		return 0, err
+	}
+
+	var counter int
+	for start := 0; start < len(ids); start += queryWriteChunkSize {
+		end := start + queryWriteChunkSize
+		if end > len(ids) {
+			end = len(ids)
+		}
+
+		// Only count the chunk once it has been committed
+		var n int
+		if err := q.db.update(func(txn *badger.Txn) (err error) {
+			n, err = fn(txn, ids[start:end])
+			return
+		}); err != nil {
+			q.debugLog(t, counter, err)
+			return counter, err
+		}
+
+		counter += n
+	}
+
+	q.debugLog(t, counter, nil)
+	return counter, nil
+}
+
+func (q *Query) deleteIDs(txn *badger.Txn, ids idList) (int, error) {
+	var counter int
+
+	for _, id := range ids {
+		if err := ctxErr(q.goCtx); err != nil {
+			return 0, err
+		}
+
+		record := q.newTargetRecord()
+
+		// The record might have been deleted since we ran the query,
+		// in which case there is nothing to do
+		if found, err := q.db.get(txn, record, noCTX, id); err != nil {
+			return 0, err
+		} else if !found {
+			continue
+		}
+
+		if err := q.db.remove(txn, record); err != nil {
+			return 0, err
+		}
+
+		counter++
+	}
+
+	return counter, nil
+}
+
+func (q *Query) updateIDs(fn func(Record) error) func(*badger.Txn, idList) (int, error) {
+	return func(txn *badger.Txn, ids idList) (int, error) {
+		var counter int
+
+		for _, id := range ids {
+			if err := ctxErr(q.goCtx); err != nil {
+				return 0, err
+			}
+
+			record := q.newTargetRecord()
+
+			// As with delete, the record might have gone since we ran the query
+			if found, err := q.db.get(txn, record, q.ctx, id); err != nil {
+				return 0, err
+			} else if !found {
+				continue
+			}
+
+			if err := fn(record); err != nil {
+				return 0, err
+			}
+
+			// A regular save takes care of the triggers,
+			// and only rewrites the index keys that have changed
+			if _, err := q.db.save(q.goCtx, txn, record); err != nil {
+				return 0, err
+			}
+
+			counter++
+		}
+
+		return counter, nil
+	}
+}
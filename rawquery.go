@@ -0,0 +1,111 @@
+package tormenta
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/dgraph-io/badger"
+	"github.com/jpincas/gouuidv6"
+)
+
+const (
+	ErrRawQueryKind   = "The type of index %s can't be worked out for a raw query on a type that isn't registered"
+	ErrRawQueryTarget = "A raw query on %s, which isn't a registered type, can only be run with RunRaw or Count"
+)
+
+// RawRecord is a record as it is stored, without being unserialised
+// (or the codec identifier, if it was stored with a codec)
+type RawRecord struct {
+	ID    gouuidv6.UUID
+	Value []byte
+}
+
+// FindRaw starts a query on the records stored under a key root (e.g. "order"),
+// for use where the Go type of the records isn't to hand, e.g. in tools which work on any data directory.
+// Run it with RunRaw to get the stored records.
+// If the type has been registered (see Register), the query works exactly as one started with Find.
+// Otherwise, index searches go by the type of the values given (as with Parse),
+// strings are matched case insensitively whatever the index mode of the field,
+// and ordering by an index isn't possible
+func (db DB) FindRaw(keyRoot string) *Query {
+	if entity, ok := db.registeredType(keyRoot); ok {
+		return db.Find(newSlice(recordValue(entity).Type(), 0))
+	}
+
+	q := &Query{
+		db:      db,
+		keyRoot: []byte(keyRoot),
+	}
+
+	q.ctx = make(map[string]interface{})
+	q.idsCombinator = intersection
+
+	return q
+}
+
+// RunRaw executes the query, returning the matching records as they are stored
+func (q *Query) RunRaw() (records []RawRecord, err error) {
+	err = q.db.run(q.operation(), func(Operation) error {
+		return q.db.view(func(txn *badger.Txn) error {
+			ids, err := q.resolveIDs(txn)
+			if err != nil {
+				return err
+			}
+
+			for _, id := range ids {
+				if err := ctxErr(q.goCtx); err != nil {
+					return err
+				}
+
+				item, err := txn.Get(newContentKey(q.keyRoot, id).bytes())
+				if err == badger.ErrKeyNotFound {
+					continue
+				} else if err != nil {
+					return err
+				}
+
+				value, err := item.ValueCopy(nil)
+				if err != nil {
+					return err
+				}
+
+				if _, value, err = splitValue(value); err != nil {
+					return err
+				}
+
+				records = append(records, RawRecord{ID: id, Value: value})
+			}
+
+			return nil
+		})
+	})
+
+	return
+}
+
+// fieldKind gets the kind of an indexed field of the entity being searched for.
+// Raw queries on types that aren't registered have no entity to go on,
+// so they use the kind of the first of the values being searched for that isn't nil
+func (q *Query) fieldKind(indexName string, values ...interface{}) (reflect.Kind, error) {
+	if q.target != nil {
+		return fieldKind(q.target, indexName)
+	}
+
+	for _, value := range values {
+		if value != nil {
+			return reflect.TypeOf(value).Kind(), nil
+		}
+	}
+
+	return 0, fmt.Errorf(ErrRawQueryKind, indexName)
+}
+
+// requireTarget checks that the query knows the type of entity it is searching for,
+// which raw queries on types that aren't registered don't, setting the error on the query if not
+func (q *Query) requireTarget() bool {
+	if q.target == nil && q.err == nil {
+		q.err = fmt.Errorf(ErrRawQueryTarget, q.keyRoot)
+	}
+
+	return q.target != nil
+}
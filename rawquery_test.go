@@ -0,0 +1,112 @@
+package tormenta_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/jpincas/tormenta"
+	"github.com/jpincas/tormenta/testtypes"
+)
+
+func Test_FindRaw(t *testing.T) {
+	db, _ := tormenta.OpenTestWithOptions("data/tests", testDBOptions)
+	defer db.Close()
+
+	for i := 0; i < 10; i++ {
+		db.Save(&testtypes.MiniStruct{IntField: i, StringField: "Jon"})
+	}
+
+	testCases := []struct {
+		testName      string
+		query         string
+		expectedCount int
+	}{
+		{"all", "", 10},
+		{"match int", "where=index:IntField,match:3", 1},
+		{"match string", "where=index:StringField,match:JON", 10},
+		{"range", "where=index:IntField,start:2,end:5", 4},
+		{"limit", "where=index:StringField,match:jon&limit=3", 3},
+	}
+
+	for _, testCase := range testCases {
+		q := db.FindRaw("ministruct")
+		if err := q.Parse(false, testCase.query); err != nil {
+			t.Fatalf("Testing raw query (%s). Couldn't parse: %v", testCase.testName, err)
+		}
+
+		records, err := q.RunRaw()
+		if err != nil {
+			t.Errorf("Testing raw query (%s). Got error %v", testCase.testName, err)
+		}
+
+		if len(records) != testCase.expectedCount {
+			t.Errorf("Testing raw query (%s). Expected %v records, got %v", testCase.testName, testCase.expectedCount, len(records))
+		}
+
+		for _, record := range records {
+			result := testtypes.MiniStruct{}
+			if err := json.Unmarshal(record.Value, &result); err != nil || result.StringField != "Jon" {
+				t.Errorf("Testing raw query (%s). Expected the stored record, got %s", testCase.testName, record.Value)
+			}
+		}
+	}
+
+	// Ordering and typed results need the type to be registered
+	if _, err := db.FindRaw("ministruct").OrderBy("IntField").RunRaw(); err == nil {
+		t.Error("Testing raw query. Expected an error ordering an unregistered type")
+	}
+
+	if _, err := db.FindRaw("ministruct").Run(); err == nil {
+		t.Error("Testing raw query. Expected an error running an unregistered type without RunRaw")
+	}
+
+	db.Register(&testtypes.MiniStruct{})
+	records, err := db.FindRaw("ministruct").OrderBy("IntField").Reverse().Limit(1).RunRaw()
+	if err != nil || len(records) != 1 {
+		t.Fatalf("Testing raw query on a registered type. Expected 1 record, got %v (error %v)", len(records), err)
+	}
+
+	result := testtypes.MiniStruct{}
+	json.Unmarshal(records[0].Value, &result)
+	if result.IntField != 9 {
+		t.Errorf("Testing raw query on a registered type. Expected the highest IntField, got %v", result.IntField)
+	}
+}
+
+func Test_StoredTypesAndIndexes(t *testing.T) {
+	db, _ := tormenta.OpenTestWithOptions("data/tests", testDBOptions)
+	defer db.Close()
+
+	entity := testtypes.MiniStruct{IntField: 1}
+	db.Save(&entity, &testtypes.MiniStruct{IntField: 2}, &testtypes.FullStruct{})
+
+	types, err := db.StoredTypes()
+	if err != nil {
+		t.Fatalf("Testing stored types. Got error %v", err)
+	}
+
+	expected := []tormenta.KeyCount{{Name: "fullstruct", Keys: 1}, {Name: "ministruct", Keys: 2}}
+	if len(types) != 2 || types[0] != expected[0] || types[1] != expected[1] {
+		t.Errorf("Testing stored types. Expected %v, got %v", expected, types)
+	}
+
+	indexes, _ := db.StoredIndexes("ministruct")
+	counts := map[string]int{}
+	for _, index := range indexes {
+		counts[index.Name] = index.Keys
+	}
+
+	if counts["IntField"] != 2 {
+		t.Errorf("Testing stored indexes. Expected 2 IntField keys, got %v", indexes)
+	}
+
+	value, found, err := db.GetRaw("ministruct", entity.ID)
+	if err != nil || !found {
+		t.Fatalf("Testing get raw. Expected to find the record, got error %v", err)
+	}
+
+	result := testtypes.MiniStruct{}
+	if json.Unmarshal(value, &result); result.IntField != 1 {
+		t.Errorf("Testing get raw. Expected the stored record, got %s", value)
+	}
+}
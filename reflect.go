@@ -3,6 +3,7 @@ package tormenta
 import (
 	"fmt"
 	"reflect"
+	"time"
 )
 
 var (
@@ -11,6 +12,8 @@ var (
 	typeFloat  = reflect.TypeOf(0.99)
 	typeString = reflect.TypeOf("")
 	typeBool   = reflect.TypeOf(true)
+	typeTime   = reflect.TypeOf(time.Time{})
+	typeModel  = reflect.TypeOf(Model{})
 )
 
 // The idea here is to keep all the reflect code in one place,
@@ -53,6 +56,30 @@ func setSingleResultOntoTarget(target interface{}, record Record) {
 	reflect.Indirect(reflect.ValueOf(target)).Set(reflect.Indirect(reflect.ValueOf(record)))
 }
 
+// recordModel returns a copy of the tormenta Model embedded in a record
+func recordModel(record Record) (Model, bool) {
+	modelField := recordValue(record).FieldByName("Model")
+	if !modelField.IsValid() {
+		return Model{}, false
+	}
+
+	model, ok := modelField.Interface().(Model)
+	return model, ok
+}
+
+// copyRecord makes a shallow copy of a record
+func copyRecord(record Record) Record {
+	c := newRecord(record)
+	recordValue(c).Set(recordValue(record))
+	return c
+}
+
+// resetRecord sets a record back to its zero value
+func resetRecord(record Record) {
+	v := recordValue(record)
+	v.Set(reflect.Zero(v.Type()))
+}
+
 func fieldValue(entity Record, fieldName string) reflect.Value {
 	return recordValue(entity).FieldByName(fieldName)
 }
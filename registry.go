@@ -0,0 +1,66 @@
+package tormenta
+
+import (
+	"reflect"
+	"sync"
+)
+
+// typeRegistry keeps track of the entity types registered with the DB,
+// so that operations covering every type (e.g. ReindexAll) know what there is
+type typeRegistry struct {
+	mu    sync.RWMutex
+	types map[string]reflect.Type
+
+	// Keep the order of registration, so that things happen predictably
+	keyRoots []string
+}
+
+func newTypeRegistry() *typeRegistry {
+	return &typeRegistry{
+		types: map[string]reflect.Type{},
+	}
+}
+
+// Register tells the DB about entity types, e.g. db.Register(&Order{}, &Customer{}).
+// Registering the same type twice has no effect.
+// It applies to all copies of the DB, including those bound to transactions
+func (db DB) Register(entities ...Record) {
+	db.registry.mu.Lock()
+	defer db.registry.mu.Unlock()
+
+	for _, entity := range entities {
+		keyRoot := KeyRootString(entity)
+		if _, ok := db.registry.types[keyRoot]; ok {
+			continue
+		}
+
+		db.registry.types[keyRoot] = recordValue(entity).Type()
+		db.registry.keyRoots = append(db.registry.keyRoots, keyRoot)
+	}
+}
+
+// RegisteredTypes returns a new, blank entity of each registered type,
+// in the order they were registered
+func (db DB) RegisteredTypes() (entities []Record) {
+	db.registry.mu.RLock()
+	defer db.registry.mu.RUnlock()
+
+	for _, keyRoot := range db.registry.keyRoots {
+		entities = append(entities, reflect.New(db.registry.types[keyRoot]).Interface().(Record))
+	}
+
+	return
+}
+
+// registeredType returns a new, blank entity of the registered type with the given key root
+func (db DB) registeredType(keyRoot string) (Record, bool) {
+	db.registry.mu.RLock()
+	defer db.registry.mu.RUnlock()
+
+	t, ok := db.registry.types[keyRoot]
+	if !ok {
+		return nil, false
+	}
+
+	return reflect.New(t).Interface().(Record), true
+}
@@ -0,0 +1,213 @@
+package tormenta
+
+import (
+	"bytes"
+
+	"github.com/dgraph-io/badger"
+)
+
+// Maintenance operations work through the keys in chunks,
+// each in its own transaction, to stay within Badger's transaction size limit
+const maintenanceChunkSize = 1000
+
+// Progress reports how far a long running maintenance operation has got
+type Progress struct {
+	KeyRoot string
+
+	// Done is the number of records processed so far, out of Total
+	Done, Total int
+}
+
+// ProgressFunc is called after each chunk of a maintenance operation
+type ProgressFunc func(Progress)
+
+// rawItem is a key/value pair read straight from Badger
+type rawItem struct {
+	key, value []byte
+	expiresAt  uint64
+}
+
+// typePrefix is the prefix of all the keys of a given kind (content, index etc.)
+// belonging to an entity type.  The trailing separator makes sure
+// that e.g. 'order' doesn't also cover 'orderline'
+func typePrefix(keyPrefix string, root []byte) []byte {
+	return bytes.Join([][]byte{[]byte(keyPrefix), root, {}}, []byte(keySeparator))
+}
+
+// scanPrefix calls fn with chunks of the items under a prefix,
+// each chunk read in a fresh transaction.
+// Values are only read if withValues is set
+func (db DB) scanPrefix(prefix []byte, withValues bool, fn func([]rawItem) error) error {
+	return db.scanPrefixFrom(prefix, prefix, withValues, fn)
+}
+
+// scanPrefixFrom is like scanPrefix, but starts from the given key
+func (db DB) scanPrefixFrom(prefix, seek []byte, withValues bool, fn func([]rawItem) error) error {
+	for {
+		var chunk []rawItem
+
+		if err := db.KV.View(func(txn *badger.Txn) error {
+			options := badger.DefaultIteratorOptions
+			options.PrefetchValues = withValues
+			it := txn.NewIterator(options)
+			defer it.Close()
+
+			for it.Seek(seek); it.ValidForPrefix(prefix) && len(chunk) < maintenanceChunkSize; it.Next() {
+				item := it.Item()
+				raw := rawItem{
+					key:       item.KeyCopy(nil),
+					expiresAt: item.ExpiresAt(),
+				}
+
+				if withValues {
+					value, err := item.ValueCopy(nil)
+					if err != nil {
+						return err
+					}
+
+					raw.value = value
+				}
+
+				chunk = append(chunk, raw)
+			}
+
+			return nil
+		}); err != nil {
+			return err
+		}
+
+		if len(chunk) == 0 {
+			return nil
+		}
+
+		if err := fn(chunk); err != nil {
+			return err
+		}
+
+		// Carry on from just after the last key
+		seek = append(chunk[len(chunk)-1].key, 0x00)
+	}
+}
+
+// decodeContent unserialises a record read straight from its content key
+func (db DB) decodeContent(entity Record, item rawItem) (Record, error) {
+	record := newRecord(entity)
+	if err := db.unserialise(item.value, record); err != nil {
+		return nil, err
+	}
+
+	record.SetID(extractID(item.key))
+	return record, nil
+}
+
+// countPrefix counts the keys under a prefix
+func (db DB) countPrefix(prefix []byte) (n int, err error) {
+	err = db.scanPrefix(prefix, false, func(chunk []rawItem) error {
+		n += len(chunk)
+		return nil
+	})
+
+	return
+}
+
+// deletePrefix deletes all the keys under a prefix and returns how many there were
+func (db DB) deletePrefix(prefix []byte) (n int, err error) {
+	err = db.scanPrefix(prefix, false, func(chunk []rawItem) error {
+		if err := db.KV.Update(func(txn *badger.Txn) error {
+			for _, item := range chunk {
+				if err := txn.Delete(item.key); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		}); err != nil {
+			return err
+		}
+
+		n += len(chunk)
+		return nil
+	})
+
+	return
+}
+
+// DropIndex deletes all the keys of a single index of an entity type,
+// e.g. one left behind by a field that has since been removed or tagged noindex,
+// and returns how many there were.  Entities saved afterwards will index the field again,
+// if it is still indexed.  It always runs in its own transactions
+func (db DB) DropIndex(entity Record, indexName string) (int, error) {
+	return db.deletePrefix(newIndexKey(KeyRoot(entity), []byte(indexName), nil).bytes())
+}
+
+// RebuildIndexes deletes all the index keys (including unique keys) of an entity type,
+// then rebuilds them from the stored content, which gets rid of stale keys
+// after struct tags or field types have changed.  It works in chunks of records,
+// each in its own transaction, calling progress (if not nil) after each chunk,
+// and returns the number of records reindexed.
+// Queries on the type won't give complete results until it has finished,
+// and it is best not to save entities of the type meanwhile
+func (db DB) RebuildIndexes(entity Record, progress ProgressFunc) (int, error) {
+	if err := checkCompositeIndexes(entity); err != nil {
+		return 0, err
+	}
+
+	root := KeyRoot(entity)
+	contentPrefix := typePrefix(contentKeyPrefix, root)
+
+	total, err := db.countPrefix(contentPrefix)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, keyPrefix := range []string{indexKeyPrefix, uniqueKeyPrefix} {
+		if _, err := db.deletePrefix(typePrefix(keyPrefix, root)); err != nil {
+			return 0, err
+		}
+	}
+
+	done := 0
+	err = db.scanPrefix(contentPrefix, true, func(chunk []rawItem) error {
+		if err := db.KV.Update(func(txn *badger.Txn) error {
+			for _, item := range chunk {
+				record, err := db.decodeContent(entity, item)
+				if err != nil {
+					return err
+				}
+
+				// Index keys expire along with the content
+				if err := index(txn, record, item.expiresAt); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		}); err != nil {
+			return err
+		}
+
+		done += len(chunk)
+		if progress != nil {
+			progress(Progress{KeyRoot: string(root), Done: done, Total: total})
+		}
+
+		return nil
+	})
+
+	return done, err
+}
+
+// ReindexAll rebuilds the indexes of every registered entity type (see Register and RebuildIndexes),
+// returning the total number of records reindexed
+func (db DB) ReindexAll(progress ProgressFunc) (int, error) {
+	total := 0
+	for _, entity := range db.RegisteredTypes() {
+		n, err := db.RebuildIndexes(entity, progress)
+		total += n
+		if err != nil {
+			return total, err
+		}
+	}
+
+	return total, nil
+}
@@ -0,0 +1,139 @@
+package tormenta_test
+
+import (
+	"testing"
+
+	"github.com/dgraph-io/badger"
+	"github.com/jpincas/gouuidv6"
+	"github.com/jpincas/tormenta"
+	"github.com/jpincas/tormenta/testtypes"
+)
+
+func Test_RebuildIndexes(t *testing.T) {
+	db, _ := tormenta.OpenTestWithOptions("data/tests", testDBOptions)
+	defer db.Close()
+
+	var entities []tormenta.Record
+	for i := 0; i < 2500; i++ {
+		entities = append(entities, &testtypes.FullStruct{IntField: i % 10})
+	}
+
+	if _, err := db.BulkLoad(entities...); err != nil {
+		t.Fatalf("Testing rebuild indexes. Couldn't load records: %v", err)
+	}
+
+	entity := entities[0].(*testtypes.FullStruct)
+
+	// Simulate a stale key, left behind by an old version of the struct,
+	// and a missing key, from an interrupted save
+	staleKey := tormenta.MakeIndexKey([]byte("fullstruct"), entity.ID, []byte("OldField"), "old")
+	missingKey := tormenta.MakeIndexKey([]byte("fullstruct"), entity.ID, []byte("IntField"), 0)
+	db.KV.Update(func(txn *badger.Txn) error {
+		txn.Set(staleKey, []byte{})
+		return txn.Delete(missingKey)
+	})
+
+	// A key of another type with a similar name shouldn't be touched
+	otherKey := tormenta.MakeIndexKey([]byte("fullstructs"), gouuidv6.New(), []byte("IntField"), 0)
+	db.KV.Update(func(txn *badger.Txn) error {
+		return txn.Set(otherKey, []byte{})
+	})
+
+	var progress []tormenta.Progress
+	n, err := db.RebuildIndexes(&testtypes.FullStruct{}, func(p tormenta.Progress) {
+		progress = append(progress, p)
+	})
+
+	if err != nil {
+		t.Fatalf("Testing rebuild indexes. Got error %v", err)
+	}
+
+	if n != 2500 {
+		t.Errorf("Testing rebuild indexes. Expected 2500 records reindexed, got %v", n)
+	}
+
+	if len(progress) != 3 || progress[2].Done != 2500 || progress[2].Total != 2500 || progress[0].KeyRoot != "fullstruct" {
+		t.Errorf("Testing rebuild indexes. Progress reporting wasn't as expected: %v", progress)
+	}
+
+	db.KV.View(func(txn *badger.Txn) error {
+		if _, err := txn.Get(staleKey); err != badger.ErrKeyNotFound {
+			t.Error("Testing rebuild indexes. Expected the stale key to be gone")
+		}
+
+		if _, err := txn.Get(missingKey); err != nil {
+			t.Error("Testing rebuild indexes. Expected the missing key to be back")
+		}
+
+		if _, err := txn.Get(otherKey); err != nil {
+			t.Error("Testing rebuild indexes. Expected the key of another type to be left alone")
+		}
+
+		return nil
+	})
+
+	if n, _ := db.Find(&[]testtypes.FullStruct{}).Match("IntField", 0).Count(); n != 250 {
+		t.Errorf("Testing rebuild indexes. Expected 250 results from the index, got %v", n)
+	}
+}
+
+func Test_DropIndex(t *testing.T) {
+	db, _ := tormenta.OpenTestWithOptions("data/tests", testDBOptions)
+	defer db.Close()
+
+	db.Save(
+		&testtypes.FullStruct{IntField: 1, StringField: "jon"},
+		&testtypes.FullStruct{IntField: 2, StringField: "jon"},
+	)
+
+	n, err := db.DropIndex(&testtypes.FullStruct{}, "IntField")
+	if err != nil {
+		t.Fatalf("Testing drop index. Got error %v", err)
+	}
+
+	if n != 2 {
+		t.Errorf("Testing drop index. Expected 2 keys dropped, got %v", n)
+	}
+
+	if n, _ := db.Find(&[]testtypes.FullStruct{}).Range("IntField", 0, 10).Count(); n != 0 {
+		t.Errorf("Testing drop index. Expected no results from the dropped index, got %v", n)
+	}
+
+	if n, _ := db.Find(&[]testtypes.FullStruct{}).Match("StringField", "jon").Count(); n != 2 {
+		t.Errorf("Testing drop index. Expected other indexes to be untouched, got %v results", n)
+	}
+}
+
+func Test_ReindexAll(t *testing.T) {
+	db, _ := tormenta.OpenTestWithOptions("data/tests", testDBOptions)
+	defer db.Close()
+
+	db.Save(&testtypes.FullStruct{IntField: 1}, &testtypes.FullStruct{IntField: 2})
+	db.Save(&testtypes.MiniStruct{IntField: 1})
+
+	db.DropIndex(&testtypes.FullStruct{}, "IntField")
+	db.DropIndex(&testtypes.MiniStruct{}, "IntField")
+
+	db.Register(&testtypes.FullStruct{}, &testtypes.MiniStruct{}, &testtypes.FullStruct{})
+
+	if types := db.RegisteredTypes(); len(types) != 2 {
+		t.Errorf("Testing register. Expected 2 registered types, got %v", len(types))
+	}
+
+	n, err := db.ReindexAll(nil)
+	if err != nil {
+		t.Fatalf("Testing reindex all. Got error %v", err)
+	}
+
+	if n != 3 {
+		t.Errorf("Testing reindex all. Expected 3 records reindexed, got %v", n)
+	}
+
+	if n, _ := db.Find(&[]testtypes.FullStruct{}).Range("IntField", 0, 10).Count(); n != 2 {
+		t.Errorf("Testing reindex all. Expected 2 full structs from the index, got %v", n)
+	}
+
+	if n, _ := db.Find(&[]testtypes.MiniStruct{}).Range("IntField", 0, 10).Count(); n != 1 {
+		t.Errorf("Testing reindex all. Expected 1 mini struct from the index, got %v", n)
+	}
+}
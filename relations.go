@@ -1,6 +1,7 @@
 package tormenta
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"log"
@@ -127,7 +128,7 @@ func LoadByQuery(db *DB, fieldName string, queryModifier QueryModifier, entities
 		return errorsList[0]
 	}
 
-	db.getIDsWithContext(txn, target, noCTX, allIDsToGet...)
+	db.getIDsWithContext(context.Background(), txn, target, noCTX, allIDsToGet...)
 
 	// Once we have all the results,
 	// we build up a map of results keyed by ID
@@ -172,9 +173,9 @@ type relationsResult struct {
 }
 
 func LoadByID(db *DB, relationsToLoad []string, entities ...Record) error {
-	txn := db.KV.NewTransaction(false)
-	defer txn.Discard()
-	return loadByID(db, txn, relationsToLoad, entities...)
+	return db.view(func(txn *badger.Txn) error {
+		return loadByID(db, txn, relationsToLoad, entities...)
+	})
 }
 
 func loadByID(db *DB, txn *badger.Txn, relationsToLoad []string, entities ...Record) error {
@@ -405,7 +406,7 @@ func getRelatedField(db *DB, txn *badger.Txn, fieldName string, entities ...Reco
 	// relations
 
 	results := newSlice(typeToGet, len(ids))
-	if _, err := db.getIDsWithContext(txn, results, noCTX, ids...); err != nil {
+	if _, err := db.getIDsWithContext(context.Background(), txn, results, noCTX, ids...); err != nil {
 		return recordMap, err
 	}
 
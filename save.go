@@ -1,6 +1,7 @@
 package tormenta
 
 import (
+	"context"
 	"fmt"
 	"reflect"
 	"time"
@@ -12,90 +13,182 @@ const (
 	errNoModel = "Cannot save entity %s - it does not have a tormenta model"
 )
 
+// Save saves one or more entities atomically, i.e. in a single transaction.
+// If the DB is bound to an explicit transaction (see Update), that transaction is used
 func (db DB) Save(entities ...Record) (int, error) {
+	return db.SaveCtx(context.Background(), entities...)
+}
 
-	err := db.KV.Update(func(txn *badger.Txn) error {
-		for i := 0; i < len(entities); i++ {
-			entity := entities[i]
+// SaveCtx is like Save, but abandons the save, rolling back the transaction and returning ctx.Err(),
+// if the context is cancelled or its deadline passes before all the entities have been saved
+func (db DB) SaveCtx(ctx context.Context, entities ...Record) (int, error) {
+	var counter int
 
-			// Make a copy of the entity and attempt to get the old
-			// version from the DB for deindexing
-			newEntity := newRecord(entity)
-			found, err := db.get(txn, newEntity, noCTX, entity.GetID())
-			if err != nil {
-				return err
-			}
+	// Versions are bumped as each entity is saved, so if the save fails
+	// we need to put them back, otherwise the entities could never be saved again.
+	// If the save succeeds but the transaction doesn't commit (e.g. a Tx that is rolled back),
+	// update puts them back instead
+	versions := recordVersions(entities)
 
-			// If it does exist, then we'll need to deindex it.
-			// If it's a new entity then deindexing is not necessary
-			if found {
-				if err := deIndex(txn, newEntity); err != nil {
-					return err
-				}
-			}
+	err := db.update(func(txn *badger.Txn) (err error) {
+		counter, err = db.save(ctx, txn, entities...)
+		return
+	})
+
+	if err != nil {
+		restoreRecordVersions(entities, versions)
+		return 0, err
+	}
+
+	return counter, nil
+}
+
+func (db DB) save(goCtx context.Context, txn *badger.Txn, entities ...Record) (int, error) {
+	// Triggers get a DB bound to this transaction,
+	// so that anything they read or write is consistent with the save
+	txDB := db.withTxn(txn)
+
+	for i := 0; i < len(entities); i++ {
+		if err := ctxErr(goCtx); err != nil {
+			return 0, err
+		}
+
+		entity := entities[i]
 
-			// Presave trigger
-			// If any more records need saving after the trigger,
+		op := Operation{
+			Type:    SaveOperation,
+			KeyRoot: KeyRootString(entity),
+			Entity:  entity,
+			Context: goCtx,
+		}
+
+		if err := db.run(op, func(Operation) error {
+			// If any more records need saving after the presave trigger,
 			// we simply add them to the list of entities to save,
 			// which keeps them in the same transaction
-			if moreRecordsToSave, err := entity.PreSave(db); err != nil {
-				return err
-			} else if len(moreRecordsToSave) > 0 {
-				entities = append(entities, moreRecordsToSave...)
-			}
+			moreRecordsToSave, err := db.saveOne(txn, txDB, entity)
+			entities = append(entities, moreRecordsToSave...)
+			return err
+		}); err != nil {
+			return 0, err
+		}
+	}
 
-			// Build the key root
-			keyRoot, e := entityTypeAndValue(entity)
+	return len(entities), nil
+}
 
-			// Check that the model field exists
-			modelField := e.FieldByName("Model")
-			if !modelField.IsValid() {
-				return fmt.Errorf(errNoModel, keyRoot)
-			}
+func (db DB) saveOne(txn *badger.Txn, txDB DB, entity Record) ([]Record, error) {
+	// Make a copy of the entity and attempt to get the old
+	// version from the DB for reindexing
+	previous := newRecord(entity)
+	found, err := db.get(txn, previous, noCTX, entity.GetID())
+	if err != nil {
+		return nil, err
+	}
 
-			// Assert the model type
-			// Check if there is an idea, if not create one
-			// Update the time last updated
-			model := modelField.Interface().(Model)
-			if model.ID.IsNil() {
-				model.ID = newID()
-			}
-			model.LastUpdated = time.Now().UTC()
+	// If it does exist, then we'll need to check that the entity
+	// we are saving is not stale.
+	// If it's a new entity then there is no previous version
+	if found {
+		if err := checkVersion(entity, previous); err != nil {
+			return nil, err
+		}
+	} else {
+		previous = nil
+	}
 
-			// Set the new model back on the entity
-			modelField.Set(reflect.ValueOf(model))
+	// Presave trigger
+	moreRecordsToSave, err := preSave(txDB, entity)
+	if err != nil {
+		return nil, err
+	}
 
-			// Before serialisation, we turn the entity
-			// into a map, with nosave fields removed
-			data, err := db.serialise(removeSkippedFields(e))
+	// Unique fields can't clash with other entities
+	if err := checkUnique(txn, entity); err != nil {
+		return nil, err
+	}
 
-			if err != nil {
-				return err
-			}
+	// Serialise and write the entity and its indexes
+	db.journal.note(txn, entity)
+	if err := db.write(txn, entity, previous); err != nil {
+		return nil, err
+	}
 
-			key := newContentKey(keyRoot, model.ID).bytes()
-			if err := txn.Set(key, data); err != nil {
-				return err
-			}
+	db.feed.record(txn, func() Change {
+		return saveChange(entity, previous)
+	})
+
+	return moreRecordsToSave, nil
+}
 
-			// Post save trigger
-			entity.PostSave()
+// write performs the part of the save process common to regular saves and bulk loading:
+// the model is updated, the entity serialised and written along with its index keys.
+// previous is the currently stored version of the entity, or nil if it is new,
+// and is used to work out which index keys need to change
+func (db DB) write(w kvWriter, entity Record, previous Record) error {
+	// Validation comes first, so that we don't touch the model
+	// of an entity which isn't going to be saved
+	if err := validate(entity); err != nil {
+		return err
+	}
 
-			// indexing
-			if err := index(txn, entity); err != nil {
-				return err
-			}
+	if err := checkCompositeIndexes(entity); err != nil {
+		return err
+	}
 
-		}
+	// Build the key root
+	keyRoot, e := entityTypeAndValue(entity)
 
-		return nil
-	})
+	// Check that the model field exists
+	modelField := e.FieldByName("Model")
+	if !modelField.IsValid() {
+		return fmt.Errorf(errNoModel, keyRoot)
+	}
 
+	// Assert the model type
+	// Check if there is an idea, if not create one
+	// Update the time last updated and bump the version
+	model := modelField.Interface().(Model)
+	if model.ID.IsNil() {
+		model.ID = newID()
+	}
+	model.LastUpdated = time.Now().UTC()
+	model.Version++
+
+	// Set the new model back on the entity
+	modelField.Set(reflect.ValueOf(model))
+
+	// Serialise without the nosave fields
+	data, err := db.serialise(entity)
 	if err != nil {
-		return 0, err
+		return err
 	}
 
-	return len(entities), nil
+	// For expiring records, the content key and all the index keys
+	// get the same expiry time, so that they disappear together
+	expiresAt, err := recordExpiresAt(entity)
+	if err != nil {
+		return err
+	}
+
+	key := newContentKey(keyRoot, model.ID).bytes()
+	if err := setEntry(w, key, data, expiresAt); err != nil {
+		return err
+	}
+
+	// Keep the soft delete marker in line with the DeletedAt field
+	wasDeleted := previous != nil && isSoftDeleted(previous)
+	if err := syncSoftDeleteMarker(w, keyRoot, model, wasDeleted, expiresAt); err != nil {
+		return err
+	}
+
+	// indexing
+	if err := reIndex(w, entity, previous, expiresAt); err != nil {
+		return err
+	}
+
+	// Post save trigger
+	return postSave(entity)
 }
 
 // The regular 'Save' function is atomic - if there is any error, the whole thing
@@ -105,7 +198,7 @@ func (db DB) Save(entities ...Record) (int, error) {
 // The total count of saved entities is returned.
 // Badger transactions have a maximum size, so the regular 'Save' function is best used
 // for a small number of entities.  This function could be used to save 1 million entities
-// if required
+// if required, although BulkLoad will be much faster
 func (db DB) SaveIndividually(entities ...Record) (counter int, lastErr error) {
 	for _, entity := range entities {
 		if _, err := db.Save(entity); err != nil {
@@ -0,0 +1,272 @@
+package tormenta
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/dgraph-io/badger"
+	"github.com/jpincas/gouuidv6"
+)
+
+// Soft deleted records keep their content and index keys,
+// so that restoring them is cheap.  Alongside, we write a marker key
+// d:root:id
+// which queries consult (key only, no fetching of records) to exclude them
+
+const (
+	softDeleteKeyPrefix = "d"
+)
+
+type deletedMode int
+
+const (
+	// excludeDeleted is the default - soft deleted records are not returned
+	excludeDeleted deletedMode = iota
+	includeDeleted
+	onlyDeleted
+)
+
+func (m deletedMode) String() string {
+	switch m {
+	case includeDeleted:
+		return "with"
+	case onlyDeleted:
+		return "only"
+	}
+
+	return ""
+}
+
+func softDeleteKey(root []byte, id gouuidv6.UUID) []byte {
+	return bytes.Join(
+		[][]byte{[]byte(softDeleteKeyPrefix), root, id.Bytes()},
+		[]byte(keySeparator),
+	)
+}
+
+func softDeleteKeyRoot(root []byte) []byte {
+	return bytes.Join(
+		[][]byte{[]byte(softDeleteKeyPrefix), root, {}},
+		[]byte(keySeparator),
+	)
+}
+
+func isSoftDeleted(record Record) bool {
+	model, ok := recordModel(record)
+	return ok && !model.DeletedAt.IsZero()
+}
+
+func syncSoftDeleteMarker(w kvWriter, root []byte, model Model, wasDeleted bool, expiresAt uint64) error {
+	if !model.DeletedAt.IsZero() {
+		return setEntry(w, softDeleteKey(root, model.ID), []byte{}, expiresAt)
+	}
+
+	if wasDeleted {
+		return w.Delete(softDeleteKey(root, model.ID))
+	}
+
+	return nil
+}
+
+func hasSoftDeleteMarker(txn *badger.Txn, root []byte, id gouuidv6.UUID) (bool, error) {
+	_, err := txn.Get(softDeleteKey(root, id))
+	if err == badger.ErrKeyNotFound {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// getLive is like get, but treats soft deleted records as not found
+func (db DB) getLive(txn *badger.Txn, entity Record, ctx map[string]interface{}, ids ...gouuidv6.UUID) (bool, error) {
+	id := entity.GetID()
+	if len(ids) > 0 {
+		id = ids[0]
+	}
+
+	if deleted, err := hasSoftDeleteMarker(txn, KeyRoot(entity), id); err != nil || deleted {
+		return false, err
+	}
+
+	return db.get(txn, entity, ctx, ids...)
+}
+
+func excludeSoftDeleted(txn *badger.Txn, root []byte, ids []gouuidv6.UUID) (liveIDs []gouuidv6.UUID, err error) {
+	deleted := newDeletedFilter(txn, root, excludeDeleted)
+	for _, id := range ids {
+		if skip, err := deleted.skip(id); err != nil {
+			return nil, err
+		} else if !skip {
+			liveIDs = append(liveIDs, id)
+		}
+	}
+
+	return
+}
+
+// deletedFilter is used during query iteration to decide whether an ID
+// should be skipped according to its soft deleted status
+type deletedFilter struct {
+	mode    deletedMode
+	txn     *badger.Txn
+	keyRoot []byte
+
+	// If there are no soft deleted records at all for this entity type,
+	// which will be the case most of the time, we can avoid looking up every ID
+	anyDeleted bool
+}
+
+func newDeletedFilter(txn *badger.Txn, keyRoot []byte, mode deletedMode) deletedFilter {
+	d := deletedFilter{
+		mode:    mode,
+		txn:     txn,
+		keyRoot: keyRoot,
+	}
+
+	if mode == includeDeleted {
+		return d
+	}
+
+	options := badger.DefaultIteratorOptions
+	options.PrefetchValues = false
+	it := txn.NewIterator(options)
+	defer it.Close()
+
+	prefix := softDeleteKeyRoot(keyRoot)
+	it.Seek(prefix)
+	d.anyDeleted = it.ValidForPrefix(prefix)
+
+	return d
+}
+
+func (d deletedFilter) skip(id gouuidv6.UUID) (bool, error) {
+	switch d.mode {
+	case includeDeleted:
+		return false, nil
+
+	case onlyDeleted:
+		if !d.anyDeleted {
+			return true, nil
+		}
+
+		deleted, err := hasSoftDeleteMarker(d.txn, d.keyRoot, id)
+		return !deleted, err
+	}
+
+	if !d.anyDeleted {
+		return false, nil
+	}
+
+	return hasSoftDeleteMarker(d.txn, d.keyRoot, id)
+}
+
+// SoftDelete marks an entity as deleted, either according to the ID set on the entity,
+// or using a separately specified ID (optional, takes priority).
+// Soft deleted entities are excluded from Get and from queries (unless WithDeleted or OnlyDeleted is used),
+// but nothing is actually removed, so they can be brought back with Restore.
+// Soft deleting an entity that is already soft deleted does nothing.
+func (db DB) SoftDelete(entity Record, ids ...gouuidv6.UUID) error {
+	return db.update(func(txn *badger.Txn) error {
+		if err := db.getForSoftDelete(txn, entity, ids...); err != nil {
+			return err
+		}
+
+		return db.run(softDeleteOperation(SoftDeleteOperation, entity), func(Operation) error {
+			if isSoftDeleted(entity) {
+				return nil
+			}
+
+			return db.setDeletedAt(txn, entity, time.Now().UTC())
+		})
+	})
+}
+
+// Restore brings back an entity that was previously soft deleted
+func (db DB) Restore(entity Record, id gouuidv6.UUID) error {
+	return db.update(func(txn *badger.Txn) error {
+		if err := db.getForSoftDelete(txn, entity, id); err != nil {
+			return err
+		}
+
+		return db.run(softDeleteOperation(RestoreOperation, entity), func(Operation) error {
+			if !isSoftDeleted(entity) {
+				return nil
+			}
+
+			return db.setDeletedAt(txn, entity, time.Time{})
+		})
+	})
+}
+
+// softDeleteOperation describes a soft delete or restore for the middleware,
+// once the entity has been retrieved
+func softDeleteOperation(opType OperationType, entity Record) Operation {
+	return Operation{
+		Type:    opType,
+		KeyRoot: KeyRootString(entity),
+		Entity:  entity,
+	}
+}
+
+func (db DB) getForSoftDelete(txn *badger.Txn, entity Record, ids ...gouuidv6.UUID) error {
+	if found, err := db.get(txn, entity, noCTX, ids...); err != nil {
+		return err
+	} else if !found {
+		return fmt.Errorf(ErrRecordNotFound, entity.GetID())
+	}
+
+	return nil
+}
+
+// setDeletedAt rewrites the content of a record with the new deletion time,
+// and updates the marker key.  Since DeletedAt is not indexed,
+// the index keys are left exactly as they are.
+func (db DB) setDeletedAt(txn *badger.Txn, entity Record, deletedAt time.Time) error {
+	keyRoot, e := entityTypeAndValue(entity)
+	modelField := e.FieldByName("Model")
+
+	// Keep a copy of the entity as it was, for the change feed
+	var before Record
+	if db.feed.active() {
+		before = copyRecord(entity)
+	}
+
+	// Soft deletion counts as a modification,
+	// so we bump the version to stop stale copies from undoing it
+	db.journal.note(txn, entity)
+	model := modelField.Interface().(Model)
+	wasDeleted := !model.DeletedAt.IsZero()
+	model.DeletedAt = deletedAt
+	model.LastUpdated = time.Now().UTC()
+	model.Version++
+	modelField.Set(reflect.ValueOf(model))
+
+	data, err := db.serialise(entity)
+	if err != nil {
+		return err
+	}
+
+	// The index keys are not being rewritten, so if the record expires,
+	// the content must keep the expiry it already has
+	expiresAt, err := contentExpiresAt(txn, keyRoot, model.ID)
+	if err != nil {
+		return err
+	}
+
+	if err := setEntry(txn, newContentKey(keyRoot, model.ID).bytes(), data, expiresAt); err != nil {
+		return err
+	}
+
+	if err := syncSoftDeleteMarker(txn, keyRoot, model, wasDeleted, expiresAt); err != nil {
+		return err
+	}
+
+	db.feed.record(txn, func() Change {
+		return newChange(Updated, entity, before, copyRecord(entity))
+	})
+	return nil
+}
@@ -0,0 +1,131 @@
+package tormenta_test
+
+import (
+	"testing"
+
+	"github.com/jpincas/tormenta"
+	"github.com/jpincas/tormenta/testtypes"
+)
+
+func Test_SoftDelete(t *testing.T) {
+	db, _ := tormenta.OpenTestWithOptions("data/tests", testDBOptions)
+	defer db.Close()
+
+	fullStruct1 := testtypes.FullStruct{IntField: 1}
+	fullStruct2 := testtypes.FullStruct{IntField: 2}
+	fullStruct3 := testtypes.FullStruct{IntField: 2}
+	db.Save(&fullStruct1, &fullStruct2, &fullStruct3)
+
+	// Keep a stale copy for later
+	stale := testtypes.FullStruct{}
+	db.Get(&stale, fullStruct2.ID)
+
+	if err := db.SoftDelete(&testtypes.FullStruct{}, fullStruct2.ID); err != nil {
+		t.Fatalf("Testing soft delete. Got error %v", err)
+	}
+
+	// Get should not find it
+	if found, _ := db.Get(&testtypes.FullStruct{}, fullStruct2.ID); found {
+		t.Error("Testing get after soft delete. Record should not have been found")
+	}
+
+	var results []testtypes.FullStruct
+	if n, _ := db.GetIDs(&results, fullStruct1.ID, fullStruct2.ID); n != 1 {
+		t.Errorf("Testing get IDs after soft delete. Expected 1 result, got %v", n)
+	}
+
+	testCases := []struct {
+		testName string
+		query    *tormenta.Query
+		expected int
+	}{
+		{"basic query", db.Find(&results), 2},
+		{"basic query with deleted", db.Find(&results).WithDeleted(), 3},
+		{"basic query only deleted", db.Find(&results).OnlyDeleted(), 1},
+		{"basic query with offset", db.Find(&results).Offset(1), 1},
+		{"basic query reversed with limit", db.Find(&results).Reverse().Limit(1), 1},
+		{"index match", db.Find(&results).Match("IntField", 2), 1},
+		{"index match with deleted", db.Find(&results).Match("IntField", 2).WithDeleted(), 2},
+		{"index match only deleted", db.Find(&results).Match("IntField", 2).OnlyDeleted(), 1},
+		{"index range", db.Find(&results).Range("IntField", 1, 2), 2},
+		{"index range with deleted", db.Find(&results).Range("IntField", 1, 2).WithDeleted(), 3},
+		{"order by", db.Find(&results).OrderBy("IntField"), 2},
+	}
+
+	for _, testCase := range testCases {
+		n, err := testCase.query.Run()
+		if err != nil {
+			t.Errorf("Testing %s after soft delete. Got error %v", testCase.testName, err)
+		}
+
+		if n != testCase.expected {
+			t.Errorf("Testing %s after soft delete. Expected %v results, got %v", testCase.testName, testCase.expected, n)
+		}
+
+		for _, result := range results {
+			if result.ID == fullStruct2.ID && result.DeletedAt.IsZero() {
+				t.Errorf("Testing %s after soft delete. Soft deleted record returned without DeletedAt", testCase.testName)
+			}
+		}
+	}
+
+	// The reversed/limited query should skip the deleted record, not stop at it
+	var latest []testtypes.FullStruct
+	db.Find(&latest).Reverse().Limit(1).Run()
+	if len(latest) != 1 || latest[0].ID != fullStruct3.ID {
+		t.Error("Testing reversed query with limit after soft delete. Expected the most recent live record")
+	}
+
+	// Saving the stale copy would undo the soft delete, so it should be refused
+	if _, err := db.Save(&stale); !tormenta.IsConflict(err) {
+		t.Errorf("Testing save of stale copy of soft deleted record. Expected a conflict, got %v", err)
+	}
+
+	// Now restore it
+	if err := db.Restore(&testtypes.FullStruct{}, fullStruct2.ID); err != nil {
+		t.Errorf("Testing restore. Got error %v", err)
+	}
+
+	restored := testtypes.FullStruct{}
+	if found, _ := db.Get(&restored, fullStruct2.ID); !found {
+		t.Error("Testing get after restore. Record should have been found")
+	} else if !restored.DeletedAt.IsZero() {
+		t.Error("Testing get after restore. DeletedAt should be zero")
+	}
+
+	if n, _ := db.Find(&results).Match("IntField", 2).Count(); n != 2 {
+		t.Errorf("Testing index match after restore. Expected 2 results, got %v", n)
+	}
+
+	if n, _ := db.Find(&results).OnlyDeleted().Count(); n != 0 {
+		t.Errorf("Testing only deleted query after restore. Expected 0 results, got %v", n)
+	}
+}
+
+func Test_SoftDelete_ThenDelete(t *testing.T) {
+	db, _ := tormenta.OpenTestWithOptions("data/tests", testDBOptions)
+	defer db.Close()
+
+	fullStruct := testtypes.FullStruct{}
+	db.Save(&fullStruct)
+	db.SoftDelete(&fullStruct)
+
+	// Soft deleting again does nothing
+	if err := db.SoftDelete(&fullStruct); err != nil {
+		t.Errorf("Testing repeated soft delete. Got error %v", err)
+	}
+
+	// Hard deleting a soft deleted record is allowed
+	if err := db.Delete(&testtypes.FullStruct{}, fullStruct.ID); err != nil {
+		t.Errorf("Testing delete of soft deleted record. Got error %v", err)
+	}
+
+	var results []testtypes.FullStruct
+	if n, _ := db.Find(&results).WithDeleted().Count(); n != 0 {
+		t.Errorf("Testing query after hard delete of soft deleted record. Expected 0 results, got %v", n)
+	}
+
+	if err := db.Restore(&testtypes.FullStruct{}, fullStruct.ID); err == nil {
+		t.Error("Testing restore of hard deleted record. Expected an error but did not get one")
+	}
+}
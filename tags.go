@@ -11,7 +11,9 @@ const (
 	tormentaTagNestedIndex = "nested"
 	tormentaTagNoSave      = "-"
 	tormentaTagSplit       = "split"
+	tormentaTagTTL         = "ttl"
 	tagSeparator           = ";"
+	tagValueSeparator      = "="
 )
 
 // Tormenta-specific tags
@@ -34,6 +36,19 @@ func isTaggedWith(field reflect.StructField, targetTags ...string) bool {
 	return false
 }
 
+// getTormentaTagValue looks for a tag of the form key=value, e.g. `tormenta:"ttl=24h"`
+// and returns the value
+func getTormentaTagValue(field reflect.StructField, targetTag string) (string, bool) {
+	for _, tag := range getTormentaTags(field) {
+		kv := strings.SplitN(strings.TrimSpace(tag), tagValueSeparator, 2)
+		if len(kv) == 2 && kv[0] == targetTag {
+			return kv[1], true
+		}
+	}
+
+	return "", false
+}
+
 // shouldIndex specifies whether a field should be indexed or not
 // according to the optional `tormenta:"noindex"` tag
 func shouldIndex(field reflect.StructField) bool {
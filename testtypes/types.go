@@ -170,11 +170,12 @@ func (t *FullStruct) PreSave(db tormenta.DB) ([]tormenta.Record, error) {
 	return nil, nil
 }
 
-func (t *FullStruct) PostSave() {
+func (t *FullStruct) PostSave() error {
 	t.IsSaved = true
+	return nil
 }
 
-func (t *FullStruct) PostGet(ctx map[string]interface{}) {
+func (t *FullStruct) PostGet(ctx map[string]interface{}) error {
 	sessionIdFromContext, ok := ctx["sessionid"]
 	if ok {
 		if sessionId, ok := sessionIdFromContext.(string); ok {
@@ -183,6 +184,7 @@ func (t *FullStruct) PostGet(ctx map[string]interface{}) {
 	}
 
 	t.Retrieved = true
+	return nil
 }
 
 type MiniStruct struct {
@@ -193,3 +195,157 @@ type MiniStruct struct {
 	FloatField  float64
 	BoolField   bool
 }
+
+// Types for expiry testing
+
+type ExpiringStruct struct {
+	tormenta.Model `tormenta:"ttl=2s"`
+
+	IntField int
+}
+
+type ExpiringByMethodStruct struct {
+	tormenta.Model
+
+	IntField int
+	Lifetime time.Duration `tormenta:"noindex"`
+}
+
+func (t ExpiringByMethodStruct) TTL() time.Duration {
+	return t.Lifetime
+}
+
+// Types for delete trigger testing
+
+type DeleteTriggerStruct struct {
+	tormenta.Model
+
+	ShouldBlockDelete bool
+	ChildIDs          []gouuidv6.UUID `tormenta:"noindex"`
+	IsDeleted         bool            `tormenta:"-"`
+}
+
+func (t *DeleteTriggerStruct) PreDelete(db tormenta.DB) ([]tormenta.Record, error) {
+	if t.ShouldBlockDelete {
+		return nil, errors.New("predelete trigger is blocking delete")
+	}
+
+	var children []tormenta.Record
+	for _, id := range t.ChildIDs {
+		child := &MiniStruct{}
+		child.SetID(id)
+		children = append(children, child)
+	}
+
+	return children, nil
+}
+
+func (t *DeleteTriggerStruct) PostDelete() error {
+	t.IsDeleted = true
+	return nil
+}
+
+// Types for validation testing
+
+type ValidatedStruct struct {
+	tormenta.Model
+
+	Name     string   `tormenta:"required;maxlen=5"`
+	Age      int      `tormenta:"min=18;max=120"`
+	Status   string   `tormenta:"oneof=draft published"`
+	Tags     []string `tormenta:"maxlen=2"`
+	Password string   `tormenta:"noindex"`
+	Rating   int      `tormenta:"omitempty;min=1;max=5"`
+}
+
+func (t *ValidatedStruct) Validate() error {
+	if t.Password == t.Name && t.Name != "" {
+		return tormenta.FieldError{
+			Field:   "Password",
+			Rule:    "notname",
+			Message: "must not be the same as the name",
+		}
+	}
+
+	return nil
+}
+
+// Types for unique constraint testing
+
+type UniqueStruct struct {
+	tormenta.Model
+
+	Email     string `tormenta:"unique"`
+	FirstName string `tormenta:"unique=fullname"`
+	LastName  string `tormenta:"unique=fullname"`
+}
+
+// Types for composite index testing
+
+type CompositeStruct struct {
+	tormenta.Model `tormenta:"index=CustomerStatus:Customer,Status;index=CustomerStatusAmount:Customer,Status,Amount"`
+
+	Customer string
+	Status   string
+	Amount   int
+}
+
+// CompositeNameClashStruct has a composite index with the same name as a field
+type CompositeNameClashStruct struct {
+	tormenta.Model `tormenta:"index=Status:Customer,Status"`
+
+	Customer string
+	Status   string
+}
+
+type CompositeIndexerStruct struct {
+	tormenta.Model
+
+	Customer  string
+	OrderDate time.Time
+}
+
+func (t CompositeIndexerStruct) Indexes() []tormenta.CompositeIndex {
+	return []tormenta.CompositeIndex{
+		{Name: "CustomerOrderDate", Fields: []string{"Customer", "OrderDate"}},
+	}
+}
+
+// Types for index mode testing
+
+type IndexModeStruct struct {
+	tormenta.Model
+
+	Name  string
+	SKU   string   `tormenta:"index=exact"`
+	City  string   `tormenta:"index=fold"`
+	Codes []string `tormenta:"index=exact"`
+}
+
+// Types for codec testing
+
+type CodecStruct struct {
+	tormenta.Model
+
+	Name    string
+	Amount  float64
+	Tags    []string
+	Address CodecAddress
+	Skipped string `tormenta:"-"`
+}
+
+type CodecAddress struct {
+	City    string
+	Skipped string `tormenta:"-"`
+}
+
+// MsgpackStruct is always stored with msgpack, whatever the DB's codec
+type MsgpackStruct struct {
+	tormenta.Model
+
+	Name string
+}
+
+func (t MsgpackStruct) Codec() tormenta.Codec {
+	return tormenta.MsgpackCodec
+}
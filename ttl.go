@@ -0,0 +1,76 @@
+package tormenta
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/dgraph-io/badger"
+	"github.com/jpincas/gouuidv6"
+)
+
+const (
+	ErrBadTTLFormat = "%s is an invalid TTL for %s. Expecting a duration like '24h'"
+)
+
+// Expirer is implemented by records that should disappear after a time.
+// It takes precedence over the `tormenta:"ttl=24h"` tag.
+// A TTL of zero (or less) means the record never expires.
+type Expirer interface {
+	TTL() time.Duration
+}
+
+// recordTTL works out how long a record should live for,
+// either from its TTL method, or from a `tormenta:"ttl=..."` tag
+// on any of its top level fields (usually the embedded Model)
+func recordTTL(record Record) (time.Duration, error) {
+	if expirer, ok := record.(Expirer); ok {
+		return expirer.TTL(), nil
+	}
+
+	t := recordValue(record).Type()
+	for i := 0; i < t.NumField(); i++ {
+		if ttlString, ok := getTormentaTagValue(t.Field(i), tormentaTagTTL); ok {
+			ttl, err := time.ParseDuration(ttlString)
+			if err != nil {
+				return 0, fmt.Errorf(ErrBadTTLFormat, ttlString, KeyRootString(record))
+			}
+
+			return ttl, nil
+		}
+	}
+
+	return 0, nil
+}
+
+// recordExpiresAt converts a record's TTL into a Badger expiry time.
+// Zero means no expiry
+func recordExpiresAt(record Record) (uint64, error) {
+	ttl, err := recordTTL(record)
+	if err != nil || ttl <= 0 {
+		return 0, err
+	}
+
+	return uint64(time.Now().Add(ttl).Unix()), nil
+}
+
+// setEntry sets a key, with an expiry time if required.
+// The content key and all the index keys of an expiring record
+// share the same expiry, so they disappear together
+func setEntry(w kvWriter, key, val []byte, expiresAt uint64) error {
+	return w.SetEntry(&badger.Entry{
+		Key:       key,
+		Value:     val,
+		ExpiresAt: expiresAt,
+	})
+}
+
+// contentExpiresAt returns the expiry time of the stored content of a record,
+// so that keys rewritten without a full save can be given the same expiry
+func contentExpiresAt(txn *badger.Txn, root []byte, id gouuidv6.UUID) (uint64, error) {
+	item, err := txn.Get(newContentKey(root, id).bytes())
+	if err != nil {
+		return 0, err
+	}
+
+	return item.ExpiresAt(), nil
+}
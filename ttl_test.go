@@ -0,0 +1,81 @@
+package tormenta_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jpincas/tormenta"
+	"github.com/jpincas/tormenta/testtypes"
+)
+
+func Test_TTL(t *testing.T) {
+	db, _ := tormenta.OpenTestWithOptions("data/tests", testDBOptions)
+	defer db.Close()
+
+	byTag := testtypes.ExpiringStruct{IntField: 1}
+	byMethod := testtypes.ExpiringByMethodStruct{IntField: 1, Lifetime: 2 * time.Second}
+	byMethodNoExpiry := testtypes.ExpiringByMethodStruct{IntField: 1}
+	if _, err := db.Save(&byTag, &byMethod, &byMethodNoExpiry); err != nil {
+		t.Fatalf("Testing save of expiring records. Got error %v", err)
+	}
+
+	var tagResults []testtypes.ExpiringStruct
+	var methodResults []testtypes.ExpiringByMethodStruct
+
+	// Straight after saving, everything should be there
+	if found, _ := db.Get(&testtypes.ExpiringStruct{}, byTag.ID); !found {
+		t.Error("Testing get before expiry. Record with TTL tag not found")
+	}
+
+	if found, _ := db.Get(&testtypes.ExpiringByMethodStruct{}, byMethod.ID); !found {
+		t.Error("Testing get before expiry. Record with TTL method not found")
+	}
+
+	if n, _ := db.Find(&tagResults).Match("IntField", 1).Count(); n != 1 {
+		t.Errorf("Testing index query before expiry. Expected 1 record with TTL tag, got %v", n)
+	}
+
+	if n, _ := db.Find(&methodResults).Match("IntField", 1).Count(); n != 2 {
+		t.Errorf("Testing index query before expiry. Expected 2 records with TTL method, got %v", n)
+	}
+
+	time.Sleep(2100 * time.Millisecond)
+
+	// Now the expiring records should have gone - content and indexes
+	if found, _ := db.Get(&testtypes.ExpiringStruct{}, byTag.ID); found {
+		t.Error("Testing get after expiry. Record with TTL tag was found")
+	}
+
+	if found, _ := db.Get(&testtypes.ExpiringByMethodStruct{}, byMethod.ID); found {
+		t.Error("Testing get after expiry. Record with TTL method was found")
+	}
+
+	if n, _ := db.Find(&tagResults).Count(); n != 0 {
+		t.Errorf("Testing query after expiry. Expected 0 records with TTL tag, got %v", n)
+	}
+
+	if n, _ := db.Find(&tagResults).Match("IntField", 1).Count(); n != 0 {
+		t.Errorf("Testing index query after expiry. Expected 0 records with TTL tag, got %v", n)
+	}
+
+	if n, _ := db.Find(&methodResults).Match("IntField", 1).Count(); n != 1 {
+		t.Errorf("Testing index query after expiry. Expected only the non-expiring record with TTL method, got %v", n)
+	}
+
+	if found, _ := db.Get(&testtypes.ExpiringByMethodStruct{}, byMethodNoExpiry.ID); !found {
+		t.Error("Testing get after expiry. Record with zero TTL should not have expired")
+	}
+}
+
+func Test_TTL_BadTag(t *testing.T) {
+	db, _ := tormenta.OpenTestWithOptions("data/tests", testDBOptions)
+	defer db.Close()
+
+	type badTTL struct {
+		tormenta.Model `tormenta:"ttl=forever"`
+	}
+
+	if _, err := db.Save(&badTTL{}); err == nil {
+		t.Error("Testing save with bad TTL tag. Expected an error but did not get one")
+	}
+}
@@ -0,0 +1,67 @@
+package tormenta
+
+import (
+	"github.com/dgraph-io/badger"
+	"github.com/jpincas/gouuidv6"
+)
+
+// Tx is an explicit transaction that can span any number of saves, deletes, gets and queries.
+// Everything done through a Tx sees the writes made earlier in the same Tx,
+// and is either committed or rolled back as a whole
+type Tx struct {
+	db DB
+}
+
+// Update runs fn inside a read-write transaction.
+// If fn returns an error, the transaction is rolled back and the error returned,
+// otherwise the transaction is committed.  If the transaction is rolled back or fails to commit,
+// the entities saved in it (including any returned by PreSave) get their versions back,
+// so that they can be saved again
+func (db DB) Update(fn func(tx *Tx) error) error {
+	return db.update(func(txn *badger.Txn) error {
+		return fn(&Tx{db: db.withTxn(txn)})
+	})
+}
+
+// View runs fn inside a read-only transaction,
+// giving a consistent view of the DB across multiple gets and queries
+func (db DB) View(fn func(tx *Tx) error) error {
+	return db.view(func(txn *badger.Txn) error {
+		return fn(&Tx{db: db.withTxn(txn)})
+	})
+}
+
+// Save saves one or more entities as part of the transaction
+func (tx *Tx) Save(entities ...Record) (int, error) {
+	return tx.db.Save(entities...)
+}
+
+// Delete deletes an entity as part of the transaction
+func (tx *Tx) Delete(entity Record, ids ...gouuidv6.UUID) error {
+	return tx.db.Delete(entity, ids...)
+}
+
+// Get retrieves an entity as part of the transaction
+func (tx *Tx) Get(entity Record, ids ...gouuidv6.UUID) (bool, error) {
+	return tx.db.Get(entity, ids...)
+}
+
+// GetWithContext retrieves an entity as part of the transaction, passing through a context
+func (tx *Tx) GetWithContext(entity Record, ctx map[string]interface{}, ids ...gouuidv6.UUID) (bool, error) {
+	return tx.db.GetWithContext(entity, ctx, ids...)
+}
+
+// GetIDs retrieves multiple entities by ID as part of the transaction
+func (tx *Tx) GetIDs(target interface{}, ids ...gouuidv6.UUID) (int, error) {
+	return tx.db.GetIDs(target, ids...)
+}
+
+// Find kicks off a query that will be executed as part of the transaction
+func (tx *Tx) Find(entities interface{}) *Query {
+	return tx.db.Find(entities)
+}
+
+// First kicks off a single result query that will be executed as part of the transaction
+func (tx *Tx) First(entity interface{}) *Query {
+	return tx.db.First(entity)
+}
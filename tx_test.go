@@ -0,0 +1,174 @@
+package tormenta_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/jpincas/tormenta"
+	"github.com/jpincas/tormenta/testtypes"
+)
+
+func Test_Tx_Commit(t *testing.T) {
+	db, _ := tormenta.OpenTestWithOptions("data/tests", testDBOptions)
+	defer db.Close()
+
+	toDelete := testtypes.MiniStruct{IntField: 1}
+	db.Save(&toDelete)
+
+	toSave1 := testtypes.MiniStruct{IntField: 2}
+	toSave2 := testtypes.MiniStruct{IntField: 3}
+
+	err := db.Update(func(tx *tormenta.Tx) error {
+		// Read the record to be deleted inside the transaction
+		retrieved := testtypes.MiniStruct{}
+		if found, err := tx.Get(&retrieved, toDelete.ID); err != nil {
+			return err
+		} else if !found {
+			return errors.New("record to delete not found inside transaction")
+		}
+
+		if err := tx.Delete(&retrieved); err != nil {
+			return err
+		}
+
+		if _, err := tx.Save(&toSave1, &toSave2); err != nil {
+			return err
+		}
+
+		// Queries inside the transaction should see its own writes
+		var results []testtypes.MiniStruct
+		if n, err := tx.Find(&results).Run(); err != nil {
+			return err
+		} else if n != 2 {
+			t.Errorf("Testing transaction reads its own writes. Expected 2 results, got %v", n)
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		t.Errorf("Testing transaction commit. Got error %v", err)
+	}
+
+	var results []testtypes.MiniStruct
+	n, _ := db.Find(&results).Run()
+	if n != 2 {
+		t.Errorf("Testing transaction commit. Expected 2 results after commit, got %v", n)
+	}
+
+	if found, _ := db.Get(&testtypes.MiniStruct{}, toDelete.ID); found {
+		t.Error("Testing transaction commit. Deleted record was still found after commit")
+	}
+}
+
+func Test_Tx_Rollback(t *testing.T) {
+	db, _ := tormenta.OpenTestWithOptions("data/tests", testDBOptions)
+	defer db.Close()
+
+	existing := testtypes.MiniStruct{IntField: 1}
+	db.Save(&existing)
+
+	rollbackErr := errors.New("rollback please")
+
+	err := db.Update(func(tx *tormenta.Tx) error {
+		if err := tx.Delete(&testtypes.MiniStruct{}, existing.ID); err != nil {
+			return err
+		}
+
+		if _, err := tx.Save(&testtypes.MiniStruct{IntField: 2}); err != nil {
+			return err
+		}
+
+		return rollbackErr
+	})
+
+	if err != rollbackErr {
+		t.Errorf("Testing transaction rollback. Expected the error from the transaction function, got %v", err)
+	}
+
+	var results []testtypes.MiniStruct
+	n, _ := db.Find(&results).Run()
+	if n != 1 {
+		t.Errorf("Testing transaction rollback. Expected 1 result, got %v", n)
+	}
+
+	if found, _ := db.Get(&testtypes.MiniStruct{}, existing.ID); !found {
+		t.Error("Testing transaction rollback. Record deleted inside rolled back transaction was not found")
+	}
+}
+
+// txRelatedStruct saves a related record along with itself
+type txRelatedStruct struct {
+	tormenta.Model
+
+	Related *testtypes.MiniStruct `tormenta:"-"`
+}
+
+func (s *txRelatedStruct) PreSave(db tormenta.DB) ([]tormenta.Record, error) {
+	return []tormenta.Record{s.Related}, nil
+}
+
+func Test_Tx_Rollback_Versions(t *testing.T) {
+	db, _ := tormenta.OpenTestWithOptions("data/tests", testDBOptions)
+	defer db.Close()
+
+	related := testtypes.MiniStruct{}
+	entity := txRelatedStruct{Related: &related}
+	db.Save(&entity)
+
+	rollbackErr := errors.New("rollback please")
+
+	// Save twice in the same transaction, so the versions are bumped twice,
+	// then roll it back
+	db.Update(func(tx *tormenta.Tx) error {
+		tx.Save(&entity)
+		tx.Save(&entity)
+		return rollbackErr
+	})
+
+	if entity.Version != 1 || related.Version != 1 {
+		t.Errorf("Testing transaction rollback. Expected the versions to be put back to 1, got %v and %v", entity.Version, related.Version)
+	}
+
+	// The entities can still be saved
+	if _, err := db.Save(&entity); err != nil {
+		t.Errorf("Testing save after transaction rollback. Got error %v", err)
+	}
+
+	if entity.Version != 2 || related.Version != 2 {
+		t.Errorf("Testing save after transaction rollback. Expected the versions to be 2, got %v and %v", entity.Version, related.Version)
+	}
+}
+
+func Test_Tx_View(t *testing.T) {
+	db, _ := tormenta.OpenTestWithOptions("data/tests", testDBOptions)
+	defer db.Close()
+
+	db.Save(&testtypes.MiniStruct{}, &testtypes.MiniStruct{})
+
+	err := db.View(func(tx *tormenta.Tx) error {
+		var results []testtypes.MiniStruct
+		if n, err := tx.Find(&results).Run(); err != nil {
+			return err
+		} else if n != 2 {
+			t.Errorf("Testing read-only transaction. Expected 2 results, got %v", n)
+		}
+
+		if n, err := tx.GetIDs(&results, results[0].ID, results[1].ID); err != nil {
+			return err
+		} else if n != 2 {
+			t.Errorf("Testing read-only transaction. Expected 2 results from GetIDs, got %v", n)
+		}
+
+		// Writing is not allowed in a read-only transaction
+		if _, err := tx.Save(&testtypes.MiniStruct{}); err == nil {
+			t.Error("Testing read-only transaction. Save should have returned an error but did not")
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		t.Errorf("Testing read-only transaction. Got error %v", err)
+	}
+}
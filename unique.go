@@ -0,0 +1,182 @@
+package tormenta
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+
+	"github.com/dgraph-io/badger"
+	"github.com/jpincas/gouuidv6"
+)
+
+// Unique key format
+// u:root:fieldname (or group name):value(s)
+// with the ID of the owning record as the value
+
+const (
+	uniqueKeyPrefix   = "u"
+	tormentaTagUnique = "unique"
+
+	// The values of the fields in a composite unique group are joined with this
+	uniqueValueSeparator = "\x00"
+
+	errUniqueViolation = "Unique constraint %s on %s violated - the value is already used by %v"
+)
+
+// ErrUniqueViolation is returned when saving an entity would break a
+// `tormenta:"unique"` or `tormenta:"unique=group"` constraint
+type ErrUniqueViolation struct {
+	KeyRoot string
+
+	// Field is the name of the unique field, or the name of the group
+	// for a composite constraint
+	Field string
+
+	// ConflictingID is the ID of the entity which already has the value
+	ConflictingID gouuidv6.UUID
+}
+
+func (e ErrUniqueViolation) Error() string {
+	return fmt.Sprintf(errUniqueViolation, e.Field, e.KeyRoot, e.ConflictingID)
+}
+
+type uniqueKey struct {
+	name string
+	key  []byte
+}
+
+type uniqueField struct {
+	name  string
+	group string
+	value reflect.Value
+	mode  indexMode
+}
+
+// uniqueKeys builds the uniqueness keys for an entity.
+// Each field tagged `unique` gets its own key, and all the fields
+// tagged `unique=group` with the same group share one key.
+// Values are encoded as they are for indexing, so strings are not case sensitive
+// unless the field is tagged `index=exact`.
+// Zero values don't take part, so, for example, any number of entities
+// can have a blank unique field
+func uniqueKeys(entity Record) (keys []uniqueKey) {
+	root := KeyRoot(entity)
+
+	var groupNames []string
+	groups := map[string][][]byte{}
+	incompleteGroups := map[string]bool{}
+
+	for _, field := range uniqueFields(recordValue(entity)) {
+		name := field.name
+		if field.group != "" {
+			name = field.group
+		}
+
+		if _, seen := groups[name]; !seen {
+			groupNames = append(groupNames, name)
+		}
+
+		if isZeroValue(field.value) {
+			incompleteGroups[name] = true
+		}
+
+		groups[name] = append(groups[name], encodeIndexValue(field.value.Interface(), field.mode))
+	}
+
+	for _, name := range groupNames {
+		if incompleteGroups[name] {
+			continue
+		}
+
+		keys = append(keys, uniqueKey{
+			name: name,
+			key:  makeUniqueKey(root, []byte(name), bytes.Join(groups[name], []byte(uniqueValueSeparator))),
+		})
+	}
+
+	return
+}
+
+func uniqueFields(v reflect.Value) (fields []uniqueField) {
+	for i := 0; i < v.NumField(); i++ {
+		fieldType := v.Type().Field(i)
+
+		if fieldType.Type.Kind() == reflect.Struct && fieldType.Anonymous {
+			fields = append(fields, uniqueFields(v.Field(i))...)
+			continue
+		}
+
+		if !v.Field(i).CanInterface() {
+			continue
+		}
+
+		field := uniqueField{name: fieldType.Name, value: v.Field(i), mode: fieldIndexMode(fieldType)}
+		if group, ok := getTormentaTagValue(fieldType, tormentaTagUnique); ok {
+			field.group = group
+			fields = append(fields, field)
+		} else if isTaggedWith(fieldType, tormentaTagUnique) {
+			fields = append(fields, field)
+		}
+	}
+
+	return
+}
+
+func makeUniqueKey(root, name, value []byte) []byte {
+	return bytes.Join(
+		[][]byte{
+			[]byte(uniqueKeyPrefix),
+			root,
+			name,
+			value,
+		},
+		[]byte(keySeparator),
+	)
+}
+
+// uniqueOwner returns the ID of the entity holding a unique key, if there is one
+func uniqueOwner(txn *badger.Txn, key []byte) (id gouuidv6.UUID, found bool, err error) {
+	item, err := txn.Get(key)
+	if err == badger.ErrKeyNotFound {
+		return id, false, nil
+	} else if err != nil {
+		return id, false, err
+	}
+
+	err = item.Value(func(val []byte) error {
+		copy(id[:], val)
+		return nil
+	})
+
+	return id, err == nil, err
+}
+
+// checkUnique makes sure that none of the unique values of the entity
+// are held by a different entity.  Since this happens inside the save transaction,
+// which also writes the unique keys, two concurrent saves of the same value can't both succeed
+func checkUnique(txn *badger.Txn, entity Record) error {
+	for _, k := range uniqueKeys(entity) {
+		id, found, err := uniqueOwner(txn, k.key)
+		if err != nil {
+			return err
+		}
+
+		if found && id != entity.GetID() {
+			return ErrUniqueViolation{
+				KeyRoot:       KeyRootString(entity),
+				Field:         k.name,
+				ConflictingID: id,
+			}
+		}
+	}
+
+	return nil
+}
+
+func uniqueKeyBytes(entity Record) (keys [][]byte) {
+	for _, k := range uniqueKeys(entity) {
+		keys = append(keys, k.key)
+	}
+
+	return
+}
@@ -0,0 +1,142 @@
+package tormenta_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/jpincas/tormenta"
+	"github.com/jpincas/tormenta/testtypes"
+)
+
+func Test_Unique(t *testing.T) {
+	db, _ := tormenta.OpenTestWithOptions("data/tests", testDBOptions)
+	defer db.Close()
+
+	first := testtypes.UniqueStruct{Email: "jon@example.com", FirstName: "Jon", LastName: "Smith"}
+	if _, err := db.Save(&first); err != nil {
+		t.Fatalf("Testing unique constraints. Couldn't save first entity: %v", err)
+	}
+
+	testCases := []struct {
+		testName      string
+		entity        testtypes.UniqueStruct
+		expectedField string
+	}{
+		{"no clash", testtypes.UniqueStruct{Email: "jane@example.com", FirstName: "Jane", LastName: "Smith"}, ""},
+		{"blank values don't clash", testtypes.UniqueStruct{FirstName: "Jon"}, ""},
+		{"more blank values don't clash", testtypes.UniqueStruct{FirstName: "Jon"}, ""},
+		{"field clash", testtypes.UniqueStruct{Email: "jon@example.com"}, "Email"},
+		{"field clash is case insensitive", testtypes.UniqueStruct{Email: "JON@example.com"}, "Email"},
+		{"group clash", testtypes.UniqueStruct{Email: "jon2@example.com", FirstName: "Jon", LastName: "Smith"}, "fullname"},
+		{"partial group doesn't clash", testtypes.UniqueStruct{Email: "jon3@example.com", FirstName: "Jon", LastName: "Jones"}, ""},
+	}
+
+	for _, testCase := range testCases {
+		entity := testCase.entity
+		_, err := db.Save(&entity)
+
+		if testCase.expectedField == "" {
+			if err != nil {
+				t.Errorf("Testing unique constraints (%s). Expected no error, got %v", testCase.testName, err)
+			}
+
+			continue
+		}
+
+		uniqueErr, ok := err.(tormenta.ErrUniqueViolation)
+		if !ok {
+			t.Errorf("Testing unique constraints (%s). Expected an ErrUniqueViolation, got %v", testCase.testName, err)
+			continue
+		}
+
+		if uniqueErr.Field != testCase.expectedField {
+			t.Errorf("Testing unique constraints (%s). Expected the violation to be on %s, got %s", testCase.testName, testCase.expectedField, uniqueErr.Field)
+		}
+
+		if uniqueErr.ConflictingID != first.ID {
+			t.Errorf("Testing unique constraints (%s). Expected the conflicting ID to be %v, got %v", testCase.testName, first.ID, uniqueErr.ConflictingID)
+		}
+	}
+}
+
+func Test_Unique_UpdateAndDelete(t *testing.T) {
+	db, _ := tormenta.OpenTestWithOptions("data/tests", testDBOptions)
+	defer db.Close()
+
+	entity := testtypes.UniqueStruct{Email: "jon@example.com"}
+	db.Save(&entity)
+
+	// Resaving an entity doesn't clash with itself
+	if _, err := db.Save(&entity); err != nil {
+		t.Errorf("Testing unique constraints on resave. Expected no error, got %v", err)
+	}
+
+	// Changing the value frees up the old one
+	entity.Email = "jon.smith@example.com"
+	if _, err := db.Save(&entity); err != nil {
+		t.Fatalf("Testing unique constraints on update. Expected no error, got %v", err)
+	}
+
+	if _, err := db.Save(&testtypes.UniqueStruct{Email: "jon@example.com"}); err != nil {
+		t.Errorf("Testing unique constraints on update. Expected the old value to be free, got %v", err)
+	}
+
+	// Deleting frees up the value
+	if err := db.Delete(&entity); err != nil {
+		t.Fatalf("Testing unique constraints on delete. Couldn't delete: %v", err)
+	}
+
+	if _, err := db.Save(&testtypes.UniqueStruct{Email: "jon.smith@example.com"}); err != nil {
+		t.Errorf("Testing unique constraints on delete. Expected the value to be free, got %v", err)
+	}
+}
+
+func Test_Unique_BulkLoad(t *testing.T) {
+	db, _ := tormenta.OpenTestWithOptions("data/tests", testDBOptions)
+	defer db.Close()
+
+	existing := testtypes.UniqueStruct{Email: "jon@example.com"}
+	db.Save(&existing)
+
+	n, err := db.BulkLoad(
+		&testtypes.UniqueStruct{Email: "jane@example.com"},
+		&testtypes.UniqueStruct{Email: "jane@example.com"},
+		&testtypes.UniqueStruct{Email: "jon@example.com"},
+	)
+
+	if n != 1 {
+		t.Errorf("Testing unique constraints on bulk load. Expected 1 record loaded, got %v", n)
+	}
+
+	loadErrs, ok := err.(tormenta.LoadErrors)
+	if !ok || len(loadErrs) != 2 {
+		t.Fatalf("Testing unique constraints on bulk load. Expected 2 load errors, got %v", err)
+	}
+
+	for _, loadErr := range loadErrs {
+		if _, ok := loadErr.Err.(tormenta.ErrUniqueViolation); !ok {
+			t.Errorf("Testing unique constraints on bulk load. Expected an ErrUniqueViolation, got %v", loadErr.Err)
+		}
+	}
+}
+
+func Test_Unique_BulkLoad_Batches(t *testing.T) {
+	db, _ := tormenta.OpenTestWithOptions("data/tests", testDBOptions)
+	defer db.Close()
+
+	// The clash is in a later batch than the original, so it is found in the DB
+	var toLoad []tormenta.Record
+	for i := 0; i < 1500; i++ {
+		toLoad = append(toLoad, &testtypes.UniqueStruct{Email: fmt.Sprintf("%v@example.com", i)})
+	}
+	toLoad = append(toLoad, &testtypes.UniqueStruct{Email: "1@example.com"})
+
+	n, err := db.BulkLoad(toLoad...)
+	if n != 1500 {
+		t.Errorf("Testing unique constraints across bulk load batches. Expected 1500 records loaded, got %v", n)
+	}
+
+	if loadErrs, ok := err.(tormenta.LoadErrors); !ok || len(loadErrs) != 1 || loadErrs[0].Position != 1500 {
+		t.Errorf("Testing unique constraints across bulk load batches. Expected the last record to fail, got %v", err)
+	}
+}
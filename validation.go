@@ -0,0 +1,247 @@
+package tormenta
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	tormentaTagRequired  = "required"
+	tormentaTagMin       = "min"
+	tormentaTagMax       = "max"
+	tormentaTagMaxLen    = "maxlen"
+	tormentaTagOneOf     = "oneof"
+	tormentaTagOmitEmpty = "omitempty"
+
+	// Options for oneof are separated by spaces, e.g. `tormenta:"oneof=draft published"`
+	oneOfSeparator = " "
+
+	ErrBadValidationTag = "Field %s has an invalid validation tag %s=%s: %s"
+)
+
+// FieldError describes a single validation failure.
+// Field is blank for failures reported by a Validate method that aren't tied to a field
+type FieldError struct {
+	Field   string
+	Rule    string
+	Message string
+}
+
+func (e FieldError) Error() string {
+	if e.Field == "" {
+		return e.Message
+	}
+
+	return e.Field + " " + e.Message
+}
+
+// ValidationError is returned by Save (and the bulk loader) when an entity fails validation,
+// either by its `tormenta` validation tags or its Validate method.
+// It lists all the failures, not just the first
+type ValidationError struct {
+	KeyRoot string
+	Errors  []FieldError
+}
+
+func (e ValidationError) Error() string {
+	messages := make([]string, len(e.Errors))
+	for i, fieldError := range e.Errors {
+		messages[i] = fieldError.Error()
+	}
+
+	return fmt.Sprintf("Validation of %s failed: %s", e.KeyRoot, strings.Join(messages, "; "))
+}
+
+// Fields lists the names of the fields which failed validation
+func (e ValidationError) Fields() (fields []string) {
+	for _, fieldError := range e.Errors {
+		if fieldError.Field != "" {
+			fields = append(fields, fieldError.Field)
+		}
+	}
+
+	return
+}
+
+// validate checks an entity against its validation tags and then its Validate method, if it has one.
+// All the failures are collected into a ValidationError
+func validate(record Record) error {
+	fieldErrors, err := validateStruct(recordValue(record))
+	if err != nil {
+		return err
+	}
+
+	if validator, ok := record.(Validator); ok {
+		if err := validator.Validate(); err != nil {
+			switch e := err.(type) {
+			case ValidationError:
+				fieldErrors = append(fieldErrors, e.Errors...)
+			case FieldError:
+				fieldErrors = append(fieldErrors, e)
+			default:
+				fieldErrors = append(fieldErrors, FieldError{
+					Rule:    "validate",
+					Message: err.Error(),
+				})
+			}
+		}
+	}
+
+	if len(fieldErrors) > 0 {
+		return ValidationError{
+			KeyRoot: KeyRootString(record),
+			Errors:  fieldErrors,
+		}
+	}
+
+	return nil
+}
+
+func validateStruct(v reflect.Value) (fieldErrors []FieldError, err error) {
+	for i := 0; i < v.NumField(); i++ {
+		fieldType := v.Type().Field(i)
+
+		// Validation tags on embedded structs apply as if they were top level
+		if fieldType.Type.Kind() == reflect.Struct && fieldType.Anonymous {
+			embeddedErrors, err := validateStruct(v.Field(i))
+			if err != nil {
+				return nil, err
+			}
+
+			fieldErrors = append(fieldErrors, embeddedErrors...)
+			continue
+		}
+
+		// Unexported fields can't be checked
+		if !v.Field(i).CanInterface() {
+			continue
+		}
+
+		// Fields tagged omitempty can be left empty, whatever their other rules
+		// (which are still checked for bad tags)
+		optional := isTaggedWith(fieldType, tormentaTagOmitEmpty) && isZeroValue(v.Field(i))
+
+		for _, tag := range getTormentaTags(fieldType) {
+			fieldError, err := validateField(fieldType.Name, v.Field(i), strings.TrimSpace(tag))
+			if err != nil {
+				return nil, err
+			}
+
+			if fieldError != nil && !optional {
+				fieldErrors = append(fieldErrors, *fieldError)
+			}
+		}
+	}
+
+	return
+}
+
+// validateField checks a single field against a single tag.
+// Tags which aren't validation rules are ignored.
+// A problem with the tag itself is returned as an error
+func validateField(fieldName string, field reflect.Value, tag string) (*FieldError, error) {
+	kv := strings.SplitN(tag, tagValueSeparator, 2)
+	rule := kv[0]
+	var param string
+	if len(kv) == 2 {
+		param = kv[1]
+	}
+
+	fail := func(message string, args ...interface{}) (*FieldError, error) {
+		return &FieldError{
+			Field:   fieldName,
+			Rule:    tag,
+			Message: fmt.Sprintf(message, args...),
+		}, nil
+	}
+
+	badTag := func(reason string) (*FieldError, error) {
+		return nil, fmt.Errorf(ErrBadValidationTag, fieldName, rule, param, reason)
+	}
+
+	switch rule {
+	case tormentaTagRequired:
+		if isZeroValue(field) {
+			return fail("is required")
+		}
+
+	case tormentaTagMin, tormentaTagMax:
+		limit, err := strconv.ParseFloat(param, 64)
+		if err != nil {
+			return badTag("expecting a number")
+		}
+
+		value, ok := numericValue(field)
+		if !ok {
+			return badTag("only number fields can have a min or max")
+		}
+
+		if rule == tormentaTagMin && value < limit {
+			return fail("must be at least %s", param)
+		}
+
+		if rule == tormentaTagMax && value > limit {
+			return fail("must be at most %s", param)
+		}
+
+	case tormentaTagMaxLen:
+		limit, err := strconv.Atoi(param)
+		if err != nil {
+			return badTag("expecting a whole number")
+		}
+
+		var length int
+		switch field.Kind() {
+		case reflect.String:
+			length = utf8.RuneCountInString(field.String())
+		case reflect.Slice, reflect.Array, reflect.Map:
+			length = field.Len()
+		default:
+			return badTag("only strings, slices and maps can have a maxlen")
+		}
+
+		if length > limit {
+			return fail("must be no longer than %v", limit)
+		}
+
+	case tormentaTagOneOf:
+		options := strings.Split(param, oneOfSeparator)
+		value := fmt.Sprint(field.Interface())
+		for _, option := range options {
+			if value == option {
+				return nil, nil
+			}
+		}
+
+		return fail("must be one of %s", strings.Join(options, ", "))
+	}
+
+	return nil, nil
+}
+
+func isZeroValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Slice, reflect.Map:
+		return v.Len() == 0
+	case reflect.Ptr, reflect.Interface:
+		return v.IsNil()
+	}
+
+	return reflect.DeepEqual(v.Interface(), reflect.Zero(v.Type()).Interface())
+}
+
+func numericValue(v reflect.Value) (float64, bool) {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), true
+	}
+
+	return 0, false
+}
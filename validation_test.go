@@ -0,0 +1,110 @@
+package tormenta_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/jpincas/tormenta"
+	"github.com/jpincas/tormenta/testtypes"
+)
+
+func Test_Validation(t *testing.T) {
+	db, _ := tormenta.OpenTestWithOptions("data/tests", testDBOptions)
+	defer db.Close()
+
+	valid := testtypes.ValidatedStruct{Name: "Jon", Age: 30, Status: "draft"}
+
+	testCases := []struct {
+		testName       string
+		entity         testtypes.ValidatedStruct
+		expectedFields []string
+	}{
+		{"valid", valid, nil},
+		{"omitempty left empty", testtypes.ValidatedStruct{Name: "Jon", Age: 30, Status: "draft"}, nil},
+		{"omitempty", testtypes.ValidatedStruct{Name: "Jon", Age: 30, Status: "draft", Rating: 9}, []string{"Rating"}},
+		{"required", testtypes.ValidatedStruct{Age: 30, Status: "draft"}, []string{"Name"}},
+		{"maxlen string", testtypes.ValidatedStruct{Name: "Jonathan", Age: 30, Status: "draft"}, []string{"Name"}},
+		{"maxlen slice", testtypes.ValidatedStruct{Name: "Jon", Age: 30, Status: "draft", Tags: []string{"a", "b", "c"}}, []string{"Tags"}},
+		{"min", testtypes.ValidatedStruct{Name: "Jon", Age: 17, Status: "draft"}, []string{"Age"}},
+		{"max", testtypes.ValidatedStruct{Name: "Jon", Age: 121, Status: "draft"}, []string{"Age"}},
+		{"oneof", testtypes.ValidatedStruct{Name: "Jon", Age: 30, Status: "archived"}, []string{"Status"}},
+		{"validate method", testtypes.ValidatedStruct{Name: "Jon", Age: 30, Status: "draft", Password: "Jon"}, []string{"Password"}},
+		{"multiple failures", testtypes.ValidatedStruct{Age: 10}, []string{"Name", "Age", "Status"}},
+	}
+
+	for _, testCase := range testCases {
+		entity := testCase.entity
+		_, err := db.Save(&entity)
+
+		if testCase.expectedFields == nil {
+			if err != nil {
+				t.Errorf("Testing validation (%s). Expected no error, got %v", testCase.testName, err)
+			}
+
+			continue
+		}
+
+		validationErr, ok := err.(tormenta.ValidationError)
+		if !ok {
+			t.Errorf("Testing validation (%s). Expected a ValidationError, got %v", testCase.testName, err)
+			continue
+		}
+
+		if !reflect.DeepEqual(validationErr.Fields(), testCase.expectedFields) {
+			t.Errorf("Testing validation (%s). Expected failures for %v, got %v", testCase.testName, testCase.expectedFields, validationErr.Fields())
+		}
+	}
+
+	// Only the valid records should have made it
+	if n, _ := db.Find(&[]testtypes.ValidatedStruct{}).Count(); n != 2 {
+		t.Errorf("Testing validation. Expected 2 records saved, got %v", n)
+	}
+}
+
+func Test_Validation_RollsBackSave(t *testing.T) {
+	db, _ := tormenta.OpenTestWithOptions("data/tests", testDBOptions)
+	defer db.Close()
+
+	valid := testtypes.ValidatedStruct{Name: "Jon", Age: 30, Status: "draft"}
+	invalid := testtypes.ValidatedStruct{Name: "Jon", Age: 3, Status: "draft"}
+
+	if _, err := db.Save(&valid, &invalid); err == nil {
+		t.Error("Testing validation of multiple entities. Expected an error but did not get one")
+	}
+
+	if n, _ := db.Find(&[]testtypes.ValidatedStruct{}).Count(); n != 0 {
+		t.Errorf("Testing validation of multiple entities. Expected nothing saved, got %v", n)
+	}
+
+	// The bulk loader validates too, but carries on with the rest
+	n, err := db.BulkLoad(&valid, &invalid)
+	if n != 1 {
+		t.Errorf("Testing validation in bulk loader. Expected 1 record loaded, got %v", n)
+	}
+
+	if loadErrs, ok := err.(tormenta.LoadErrors); !ok || len(loadErrs) != 1 {
+		t.Errorf("Testing validation in bulk loader. Expected a single load error, got %v", err)
+	} else if _, ok := loadErrs[0].Err.(tormenta.ValidationError); !ok {
+		t.Errorf("Testing validation in bulk loader. Expected a ValidationError, got %v", loadErrs[0].Err)
+	}
+}
+
+type badValidationTagStruct struct {
+	tormenta.Model
+
+	Name string `tormenta:"min=1"`
+}
+
+func Test_Validation_BadTag(t *testing.T) {
+	db, _ := tormenta.OpenTestWithOptions("data/tests", testDBOptions)
+	defer db.Close()
+
+	_, err := db.Save(&badValidationTagStruct{Name: "a"})
+	if err == nil {
+		t.Fatal("Testing bad validation tag. Expected an error but did not get one")
+	}
+
+	if _, ok := err.(tormenta.ValidationError); ok {
+		t.Error("Testing bad validation tag. Expected a plain error, not a ValidationError")
+	}
+}
@@ -0,0 +1,203 @@
+package tormenta
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/dgraph-io/badger"
+	"github.com/jpincas/gouuidv6"
+)
+
+const (
+	errVersionConflict = "Cannot save entity %s with ID %v - version %v is stale, stored version is %v"
+
+	// maxMutateAttempts is the number of times Mutate will retry in the face of conflicts
+	maxMutateAttempts = 10
+)
+
+// ErrVersionConflict is returned by Save when the version of the entity being saved
+// does not match the version currently stored, i.e. someone else has saved it
+// since it was loaded.  The entity should be reloaded, and the changes re-applied.
+type ErrVersionConflict struct {
+	KeyRoot       string
+	ID            gouuidv6.UUID
+	Version       int64
+	StoredVersion int64
+}
+
+func (e ErrVersionConflict) Error() string {
+	return fmt.Sprintf(errVersionConflict, e.KeyRoot, e.ID, e.Version, e.StoredVersion)
+}
+
+// IsConflict reports whether an error is the result of a concurrent modification,
+// either detected by version checking, or by Badger's transaction conflict detection,
+// even if it has been wrapped (e.g. by middleware or a trigger, with %w)
+func IsConflict(err error) bool {
+	var conflict ErrVersionConflict
+	return errors.As(err, &conflict) || errors.Is(err, badger.ErrConflict)
+}
+
+// checkVersion compares the version of an entity that is about to be saved
+// with the version of the stored copy
+func checkVersion(entity, stored Record) error {
+	model, ok := recordModel(entity)
+	if !ok {
+		return nil
+	}
+
+	storedModel, ok := recordModel(stored)
+	if !ok {
+		return nil
+	}
+
+	if model.Version != storedModel.Version {
+		return ErrVersionConflict{
+			KeyRoot:       KeyRootString(entity),
+			ID:            model.ID,
+			Version:       model.Version,
+			StoredVersion: storedModel.Version,
+		}
+	}
+
+	return nil
+}
+
+func recordVersions(records []Record) []int64 {
+	versions := make([]int64, len(records))
+	for i, record := range records {
+		if model, ok := recordModel(record); ok {
+			versions[i] = model.Version
+		}
+	}
+
+	return versions
+}
+
+func restoreRecordVersions(records []Record, versions []int64) {
+	for i, record := range records {
+		setRecordVersion(record, versions[i])
+	}
+}
+
+func setRecordVersion(record Record, version int64) {
+	versionField := recordValue(record).FieldByName("Model").FieldByName("Version")
+	if versionField.IsValid() && versionField.Kind() == reflect.Int64 {
+		versionField.SetInt(version)
+	}
+}
+
+// versionJournal keeps track of the versions of the entities written by each transaction in progress,
+// before they were bumped, so that they can be put back if the transaction is rolled back
+// or fails to commit.  Otherwise the entities would be left ahead of the stored versions,
+// and could never be saved again.  This includes entities saved through a Tx,
+// and those returned by PreSave triggers.
+type versionJournal struct {
+	mu      sync.Mutex
+	pending map[*badger.Txn][]savedVersion
+}
+
+type savedVersion struct {
+	record  Record
+	version int64
+}
+
+func newVersionJournal() *versionJournal {
+	return &versionJournal{
+		pending: map[*badger.Txn][]savedVersion{},
+	}
+}
+
+// begin starts keeping track of the versions written by a transaction
+func (j *versionJournal) begin(txn *badger.Txn) {
+	if j == nil {
+		return
+	}
+
+	j.mu.Lock()
+	j.pending[txn] = nil
+	j.mu.Unlock()
+}
+
+// end stops keeping track of the versions written by a transaction and returns them
+func (j *versionJournal) end(txn *badger.Txn) []savedVersion {
+	if j == nil {
+		return nil
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	saved := j.pending[txn]
+	delete(j.pending, txn)
+	return saved
+}
+
+// note remembers the version of an entity which is about to be bumped by a transaction
+func (j *versionJournal) note(txn *badger.Txn, record Record) {
+	if j == nil {
+		return
+	}
+
+	model, ok := recordModel(record)
+	if !ok {
+		return
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if saved, ok := j.pending[txn]; ok {
+		j.pending[txn] = append(saved, savedVersion{record: record, version: model.Version})
+	}
+}
+
+// restoreVersions puts back the versions noted by a transaction.
+// They are restored in reverse, so an entity written more than once
+// ends up with the version it had before the first write
+func restoreVersions(saved []savedVersion) {
+	for i := len(saved) - 1; i >= 0; i-- {
+		setRecordVersion(saved[i].record, saved[i].version)
+	}
+}
+
+// Mutate loads the entity with the given ID, applies the changes made by fn
+// and saves it again, all inside a single transaction.
+// If the save fails because of a concurrent modification, the whole process is retried
+// with a freshly loaded entity, so fn should be safe to run multiple times.
+// If fn returns an error, nothing is saved and the error is returned.
+func (db DB) Mutate(entity Record, id gouuidv6.UUID, fn func() error) (err error) {
+	for attempt := 0; attempt < maxMutateAttempts; attempt++ {
+		err = db.Update(func(tx *Tx) error {
+			// Start from a clean slate each time,
+			// so nothing from a previous attempt leaks through
+			resetRecord(entity)
+
+			if found, err := tx.Get(entity, id); err != nil {
+				return err
+			} else if !found {
+				return fmt.Errorf(ErrRecordNotFound, id)
+			}
+
+			if err := fn(); err != nil {
+				return err
+			}
+
+			_, err := tx.Save(entity)
+			return err
+		})
+
+		if !IsConflict(err) {
+			return err
+		}
+
+		// Back off a little before trying again,
+		// to give whoever we conflicted with the chance to finish
+		time.Sleep(time.Duration(rand.Intn(attempt+1)+1) * time.Millisecond)
+	}
+
+	return err
+}
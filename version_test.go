@@ -0,0 +1,144 @@
+package tormenta_test
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/jpincas/tormenta"
+	"github.com/jpincas/tormenta/testtypes"
+)
+
+func Test_Version_Conflict(t *testing.T) {
+	db, _ := tormenta.OpenTestWithOptions("data/tests", testDBOptions)
+	defer db.Close()
+
+	miniStruct := testtypes.MiniStruct{}
+	db.Save(&miniStruct)
+
+	if miniStruct.Version != 1 {
+		t.Errorf("Testing version after first save. Expected 1, got %v", miniStruct.Version)
+	}
+
+	// Two workers load the same record
+	worker1 := testtypes.MiniStruct{}
+	worker2 := testtypes.MiniStruct{}
+	db.Get(&worker1, miniStruct.ID)
+	db.Get(&worker2, miniStruct.ID)
+
+	// First one to save wins
+	worker1.IntField = 1
+	if _, err := db.Save(&worker1); err != nil {
+		t.Errorf("Testing first save of a loaded record. Got error %v", err)
+	}
+
+	if worker1.Version != 2 {
+		t.Errorf("Testing version after second save. Expected 2, got %v", worker1.Version)
+	}
+
+	// Second one is now stale
+	worker2.IntField = 2
+	_, err := db.Save(&worker2)
+	conflict, ok := err.(tormenta.ErrVersionConflict)
+	if !ok {
+		t.Fatalf("Testing save of a stale record. Expected ErrVersionConflict, got %v", err)
+	}
+
+	if conflict.ID != miniStruct.ID || conflict.Version != 1 || conflict.StoredVersion != 2 {
+		t.Errorf("Testing save of a stale record. Conflict error has wrong details: %+v", conflict)
+	}
+
+	if !tormenta.IsConflict(err) {
+		t.Error("Testing save of a stale record. IsConflict should be true but was not")
+	}
+
+	if !tormenta.IsConflict(fmt.Errorf("saving order: %w", err)) {
+		t.Error("Testing a wrapped conflict. IsConflict should be true but was not")
+	}
+
+	// A failed save should not bump the version
+	if worker2.Version != 1 {
+		t.Errorf("Testing version after failed save. Expected 1, got %v", worker2.Version)
+	}
+
+	retrieved := testtypes.MiniStruct{}
+	db.Get(&retrieved, miniStruct.ID)
+	if retrieved.IntField != 1 {
+		t.Errorf("Testing stored record after conflict. Expected IntField to be 1, got %v", retrieved.IntField)
+	}
+}
+
+func Test_Mutate(t *testing.T) {
+	db, _ := tormenta.OpenTestWithOptions("data/tests", testDBOptions)
+	defer db.Close()
+
+	miniStruct := testtypes.MiniStruct{}
+	db.Save(&miniStruct)
+
+	noWorkers := 4
+	noIncrements := 5
+
+	var wg sync.WaitGroup
+	errs := make(chan error, noWorkers*noIncrements)
+
+	for i := 0; i < noWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < noIncrements; j++ {
+				record := testtypes.MiniStruct{}
+				if err := db.Mutate(&record, miniStruct.ID, func() error {
+					record.IntField++
+					return nil
+				}); err != nil {
+					errs <- err
+				}
+			}
+		}()
+	}
+
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Errorf("Testing concurrent mutate. Got error %v", err)
+	}
+
+	retrieved := testtypes.MiniStruct{}
+	db.Get(&retrieved, miniStruct.ID)
+	if retrieved.IntField != noWorkers*noIncrements {
+		t.Errorf("Testing concurrent mutate. Expected IntField to be %v, got %v", noWorkers*noIncrements, retrieved.IntField)
+	}
+}
+
+func Test_Mutate_Errors(t *testing.T) {
+	db, _ := tormenta.OpenTestWithOptions("data/tests", testDBOptions)
+	defer db.Close()
+
+	miniStruct := testtypes.MiniStruct{IntField: 1}
+	db.Save(&miniStruct)
+
+	// Errors from the mutation function abort the save
+	mutateErr := errors.New("do not save")
+	record := testtypes.MiniStruct{}
+	err := db.Mutate(&record, miniStruct.ID, func() error {
+		record.IntField = 100
+		return mutateErr
+	})
+
+	if err != mutateErr {
+		t.Errorf("Testing mutate with failing function. Expected the function's error, got %v", err)
+	}
+
+	retrieved := testtypes.MiniStruct{}
+	db.Get(&retrieved, miniStruct.ID)
+	if retrieved.IntField != 1 {
+		t.Errorf("Testing mutate with failing function. Expected IntField to be 1, got %v", retrieved.IntField)
+	}
+
+	// Mutating a record that doesn't exist is an error
+	if err := db.Mutate(&testtypes.MiniStruct{}, testtypes.MiniStruct{}.ID, func() error { return nil }); err == nil {
+		t.Error("Testing mutate of non-existent record. Expected an error but did not get one")
+	}
+}